@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/record"
+	common "linkerd-easyauth/pkg"
+	"time"
+)
+
+var (
+	checkFindings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_check_findings",
+		Help: "Number of findings reported by an easyauth check",
+	}, []string{"check"})
+
+	serversWithoutPolicy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_servers_without_policy",
+		Help: "Servers that are not targeted by any policy",
+	}, []string{"namespace", "server"})
+
+	policiesWithoutServer = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_policies_without_server",
+		Help: "ServerAuthorizations and AuthorizationPolicies that don't apply to any Server",
+	}, []string{"namespace", "kind", "name"})
+
+	obsoleteHTTPRoutes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_obsolete_httproutes",
+		Help: "HTTPRoutes with parentRefs that don't point to existing Servers",
+	}, []string{"namespace", "httproute"})
+
+	uncoveredPorts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_uncovered_ports",
+		Help: "Ports of meshed pods exposed by Services that are not covered by any Server",
+	}, []string{"namespace", "pod", "port"})
+
+	obsoleteAuthentications = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_obsolete_authentications",
+		Help: "MeshTLSAuthentications and NetworkAuthentications that are not used by any policy",
+	}, []string{"namespace", "kind", "name"})
+
+	auditErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "easyauth_audit_errors_total",
+		Help: "Number of audits that failed",
+	})
+
+	lastAudit = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "easyauth_last_audit_timestamp_seconds",
+		Help: "Unix time of the last successful audit",
+	})
+)
+
+// Auditor re-evaluates easyauth checks on every change of watched resources
+type Auditor struct {
+	source                *common.InformerSource
+	controlPlaneNamespace string
+	debounce              time.Duration
+	changes               chan struct{}
+	recorder              record.EventRecorder
+	reported              map[string]bool
+}
+
+// NewAuditor creates the auditor, changes that come within debounce interval are audited together.
+// When recorder is not nil new findings are also recorded as events on the offending objects
+func NewAuditor(source *common.InformerSource, controlPlaneNamespace string, debounce time.Duration, recorder record.EventRecorder) *Auditor {
+	a := &Auditor{
+		source:                source,
+		controlPlaneNamespace: controlPlaneNamespace,
+		debounce:              debounce,
+		changes:               make(chan struct{}, 1),
+		recorder:              recorder,
+		reported:              map[string]bool{},
+	}
+
+	source.OnChange(a.changed)
+
+	return a
+}
+
+// Run audits resources on changes until the context is done
+func (a *Auditor) Run(ctx context.Context) {
+	a.changed()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.changes:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.debounce):
+		}
+
+		if err := a.audit(ctx); err != nil {
+			auditErrors.Inc()
+			log.Errorf("failed to audit resources: %s", err)
+		}
+	}
+}
+
+func (a *Auditor) changed() {
+	select {
+	case a.changes <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Auditor) audit(ctx context.Context) error {
+	resources, err := a.source.Fetch(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	// checks match objects by name, so each namespace is checked separately
+	results := map[string][]common.Finding{}
+	for _, check := range common.EasyAuthChecks(a.controlPlaneNamespace) {
+		findings, err := check.RunPerNamespace(resources)
+		if err != nil {
+			return err
+		}
+		results[check.ID] = findings
+	}
+
+	checkFindings.Reset()
+	serversWithoutPolicy.Reset()
+	policiesWithoutServer.Reset()
+	obsoleteHTTPRoutes.Reset()
+	uncoveredPorts.Reset()
+	obsoleteAuthentications.Reset()
+
+	for id, findings := range results {
+		checkFindings.WithLabelValues(id).Set(float64(len(findings)))
+
+		for _, finding := range findings {
+			obj := finding.Object
+
+			switch id {
+			case common.ServerWithoutPolicyRule:
+				serversWithoutPolicy.WithLabelValues(obj.Namespace, obj.Name).Set(1)
+			case common.PolicyWithoutServerRule:
+				policiesWithoutServer.WithLabelValues(obj.Namespace, obj.Kind, obj.Name).Set(1)
+			case common.ObsoleteHTTPRouteRule:
+				obsoleteHTTPRoutes.WithLabelValues(obj.Namespace, obj.Name).Set(1)
+			case common.PortWithoutServerRule:
+				uncoveredPorts.WithLabelValues(obj.Namespace, obj.Name, finding.Port).Set(1)
+			case common.ObsoleteAuthenticationRule:
+				obsoleteAuthentications.WithLabelValues(obj.Namespace, obj.Kind, obj.Name).Set(1)
+			}
+		}
+
+		log.Debugf("%s: %d findings", id, len(findings))
+	}
+
+	if a.recorder != nil {
+		a.recordEvents(resources, results)
+	}
+
+	lastAudit.SetToCurrentTime()
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	l5dscheme "github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned/scheme"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	common "linkerd-easyauth/pkg"
+)
+
+// eventReasons are short CamelCase reasons shown by kubectl describe for each check
+var eventReasons = map[string]string{
+	common.ServerWithoutPolicyRule:    "ServerWithoutPolicy",
+	common.PolicyWithoutServerRule:    "PolicyWithoutServer",
+	common.ObsoleteHTTPRouteRule:      "ObsoleteHTTPRoute",
+	common.PortWithoutServerRule:      "PortWithoutServer",
+	common.ObsoleteAuthenticationRule: "ObsoleteAuthentication",
+	common.LegacyMarkerRule:           "LegacyMarker",
+	common.DenyUncoveredPortRule:      "DenyUncoveredPort",
+}
+
+// eventMessages are written from the point of view of the object the event is recorded on,
+// checks without a message here use the finding message
+var eventMessages = map[string]string{
+	common.ServerWithoutPolicyRule:    "no AuthorizationPolicy targets this Server",
+	common.PolicyWithoutServerRule:    "this policy doesn't apply to any Server",
+	common.ObsoleteHTTPRouteRule:      "parentRefs of this HTTPRoute don't point to existing Servers",
+	common.ObsoleteAuthenticationRule: "this authentication is not used by any AuthorizationPolicy",
+}
+
+// NewEventRecorder creates a recorder that writes events on behalf of the component,
+// Linkerd policy types are registered so that events can reference them
+func NewEventRecorder(client kubernetes.Interface, component string) (record.EventRecorder, error) {
+	scheme := runtime.NewScheme()
+	if err := clientsetscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := l5dscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(v1.NamespaceAll),
+	})
+
+	return eventBroadcaster.NewRecorder(scheme, v1.EventSource{Component: component}), nil
+}
+
+// recordEvents emits a warning event for every finding that was not reported by the previous audit,
+// repeated findings are not recorded again to keep events from flooding the API server
+func (a *Auditor) recordEvents(resources *common.K8sResources, results map[string][]common.Finding) {
+	reported := map[string]bool{}
+
+	for id, findings := range results {
+		for _, finding := range findings {
+			key := id + "/" + finding.Object.String() + "/" + finding.Port
+			reported[key] = true
+
+			if a.reported[key] {
+				continue
+			}
+
+			obj := resources.Object(finding.Object)
+			if obj == nil {
+				log.Debugf("skipping event for %s: object not found", finding.Object)
+				continue
+			}
+
+			message, ok := eventMessages[id]
+			if !ok {
+				message = finding.Message
+			}
+
+			a.recorder.Event(obj, v1.EventTypeWarning, eventReasons[id], message)
+		}
+	}
+
+	a.reported = reported
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/linkerd/linkerd2/pkg/admin"
+	"github.com/linkerd/linkerd2/pkg/flags"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/record"
+	"linkerd-easyauth/auditor/audit"
+	common "linkerd-easyauth/pkg"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const componentName = "linkerd-easyauth-auditor"
+
+func main() {
+	cmd := flag.NewFlagSet("auditor", flag.ExitOnError)
+	metricsAddr := cmd.String("metrics-addr", fmt.Sprintf(":%d", 9995),
+		"address to serve scrapable metrics on")
+	kubeconfig := cmd.String("kubeconfig", "", "path to kubeconfig")
+	controlPlaneNamespace := cmd.String("linkerd-namespace", "linkerd", "namespace in which Linkerd is installed")
+	debounce := cmd.Duration("debounce", 5*time.Second, "time to wait for more changes before the audit")
+	resync := cmd.Duration("resync", 10*time.Minute, "informers resync period")
+	enablePprof := cmd.Bool("enable-pprof", false, "Enable pprof endpoints on the admin server")
+	events := cmd.Bool("events", false, "record Kubernetes events on objects with new findings")
+
+	flags.ConfigureAndParse(cmd, os.Args[1:])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	k8sAPI, err := k8s.NewAPI(*kubeconfig, "", "", []string{}, 0)
+	if err != nil {
+		log.Fatalf("failed to create Kubernetes API client: %s", err)
+	}
+
+	source, err := common.NewInformerSource(ctx, k8sAPI, *resync)
+	if err != nil {
+		log.Fatalf("failed to start informers: %s", err)
+	}
+
+	var recorder record.EventRecorder
+	if *events {
+		recorder, err = audit.NewEventRecorder(k8sAPI.Interface, componentName)
+		if err != nil {
+			log.Fatalf("failed to create event recorder: %s", err)
+		}
+	}
+
+	adminServer := admin.NewServer(*metricsAddr, *enablePprof)
+	go func() {
+		log.Infof("starting admin server on %s", *metricsAddr)
+		if err := adminServer.ListenAndServe(); err != nil {
+			log.Errorf("failed to start %s admin server: %s", componentName, err)
+		}
+	}()
+
+	go audit.NewAuditor(source, *controlPlaneNamespace, *debounce, recorder).Run(ctx)
+
+	<-stop
+	log.Infof("shutting down %s", componentName)
+	adminServer.Shutdown(ctx)
+}
@@ -0,0 +1,5 @@
+#!/usr/bin/env bash
+
+set -eu
+
+docker build --build-arg LINKERD_VERSION=2.12.0 -f ./controller/Dockerfile -t aatarasoff/linkerd-easyauth-controller:${TAG:-latest} .
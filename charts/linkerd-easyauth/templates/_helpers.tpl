@@ -0,0 +1,15 @@
+{{/*
+CIDRs of policies.clusterNetwork, one per line, generated ones included
+*/}}
+{{- define "easyauth.clusterNetworks" -}}
+{{- range .Values.policies.clusterNetwork.cidr }}
+{{ . }}
+{{- end }}
+{{- if .Values.policies.clusterNetwork.generator }}
+{{- range $i, $e1 := untilStep (int $.Values.policies.clusterNetwork.generator.low1) (int $.Values.policies.clusterNetwork.generator.high1) 1 }}
+{{- range $j, $e2 := untilStep (int $.Values.policies.clusterNetwork.generator.low2) (int $.Values.policies.clusterNetwork.generator.high2) 1 }}
+{{ $.Values.policies.clusterNetwork.generator.octet0 }}.{{ $e1 }}.{{ $e2 }}.{{ $.Values.policies.clusterNetwork.generator.octet3 }}/32
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
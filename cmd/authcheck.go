@@ -2,28 +2,52 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
 	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
 	"github.com/linkerd/linkerd2/pkg/healthcheck"
-	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	common "linkerd-easyauth/pkg"
 	"os"
-	"reflect"
+	"sigs.k8s.io/yaml"
 	"strings"
 	"time"
 )
 
 const (
 	linkerdEasyAuthExtensionCheck healthcheck.CategoryID = "linkerd-easyauth"
+
+	tableOutput = healthcheck.TableOutput
+	jsonOutput  = healthcheck.JSONOutput
+	yamlOutput  = "yaml"
+
+	checkSuccess = "success"
 )
 
 type authCheckOptions struct {
 	namespace     string
 	allNamespaces bool
+	output        string
+}
+
+type checkResult struct {
+	ID          string           `json:"id"`
+	Description string           `json:"description"`
+	Result      string           `json:"result"`
+	Severity    common.Severity  `json:"severity"`
+	Error       string           `json:"error,omitempty"`
+	Findings    []common.Finding `json:"findings,omitempty"`
+}
+
+type checkCategory struct {
+	Name   string        `json:"categoryName"`
+	Checks []checkResult `json:"checks"`
+}
+
+type checkOutput struct {
+	Success    bool            `json:"success"`
+	Categories []checkCategory `json:"categories"`
 }
 
 func newCmdAuthCheck() *cobra.Command {
@@ -32,8 +56,15 @@ func newCmdAuthCheck() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "authcheck [flags]",
 		Short: "Lists which pods use easyauth configuration",
-		Args:  cobra.NoArgs,
+		Long: `Checks easyauth configuration in the cluster.
+
+Use lint to run the same checks against manifests.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateCheckOutput(options.output); err != nil {
+				return err
+			}
+
 			if options.namespace == "" {
 				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
 			}
@@ -41,26 +72,15 @@ func newCmdAuthCheck() *cobra.Command {
 				options.namespace = v1.NamespaceAll
 			}
 
-			resources, err := FetchK8sResources(cmd.Context(), options.namespace)
+			resources, err := newResourceSource(nil).Fetch(cmd.Context(), options.namespace)
 			if err != nil {
 				return err
 			}
 
-			hc := healthcheck.NewHealthChecker([]healthcheck.CategoryID{}, &healthcheck.Options{
-				ControlPlaneNamespace: controlPlaneNamespace,
-				KubeConfig:            kubeconfigPath,
-				KubeContext:           kubeContext,
-				Impersonate:           impersonate,
-				ImpersonateGroup:      impersonateGroup,
-				APIAddr:               apiAddr,
-				RetryDeadline:         time.Now().Add(600),
-				DataPlaneNamespace:    options.namespace,
-			})
-
-			hc.AppendCategories(easyAuthCategory(resources))
-
-			success, warning := healthcheck.RunChecks(stdout, stderr, hc, healthcheck.TableOutput)
-			healthcheck.PrintChecksResult(stdout, healthcheck.TableOutput, success, warning)
+			success, err := runEasyAuthChecks(resources, options.namespace, options.output)
+			if err != nil {
+				return err
+			}
 
 			if !success {
 				os.Exit(1)
@@ -72,6 +92,7 @@ func newCmdAuthCheck() *cobra.Command {
 
 	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to list pods in")
 	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, list pods across all namespaces")
+	cmd.Flags().StringVarP(&options.output, "output", "o", tableOutput, fmt.Sprintf("Output format. One of: %s, %s, %s", tableOutput, jsonOutput, yamlOutput))
 
 	pkgcmd.ConfigureNamespaceFlagCompletion(
 		cmd, []string{"namespace"},
@@ -80,305 +101,122 @@ func newCmdAuthCheck() *cobra.Command {
 	return cmd
 }
 
-func easyAuthCategory(resources *K8sResources) *healthcheck.Category {
-	checkers := []healthcheck.Checker{}
-
-	checkers = append(checkers,
-		*healthcheck.NewChecker("linkerd-easyauth no Server without authorization policies").
-			Warning().
-			WithCheck(func(ctx context.Context) error {
-				serversWOServerAuthorizations := []string{}
-
-				for _, server := range resources.Servers {
-					founded := false
-
-					for _, serverAuthorization := range resources.ServerAuthorizations {
-						selector, err := metav1.LabelSelectorAsSelector(serverAuthorization.Spec.Server.Selector)
-						if err != nil {
-							return err
-						}
-
-						if selector.Matches(labels.Set(server.GetLabels())) {
-							founded = true
-							break
-						}
-
-						if serverAuthorization.Spec.Server.Name == server.GetName() {
-							founded = true
-							break
-						}
-					}
-
-					if !founded {
-						for _, policy := range resources.AuthorizationPolicies {
-							// namespaced policies applies on each server
-							if policy.Spec.TargetRef.Kind == "Namespace" && policy.GetNamespace() == server.Namespace {
-								founded = true
-								break
-							}
-
-							if policy.Spec.TargetRef.Kind == k8s.ServerKind && string(policy.Spec.TargetRef.Name) == server.GetName() {
-								founded = true
-								break
-							}
-
-							if policy.Spec.TargetRef.Kind == k8s.HTTPRouteKind {
-								for _, httpRoute := range resources.HTTPRoutes {
-									for _, targetRef := range httpRoute.Spec.ParentRefs {
-										if *targetRef.Kind == k8s.ServerKind && string(targetRef.Name) == server.GetName() && string(policy.Spec.TargetRef.Name) == httpRoute.GetName() {
-											founded = true
-											break
-										}
-									}
-								}
-							}
-						}
-					}
-
-					if !founded {
-						serversWOServerAuthorizations = append(serversWOServerAuthorizations, fmt.Sprintf("Server %s has no authorization policies", server.GetName()))
-					}
-				}
-
-				if len(serversWOServerAuthorizations) == 0 {
-					return nil
-				}
-				return fmt.Errorf("Some servers have no authorization policies:\n\t%s", strings.Join(serversWOServerAuthorizations, "\n\t"))
-			}))
-
-	checkers = append(checkers,
-		*healthcheck.NewChecker("linkerd-easyauth no authorization policies without Server").
-			Warning().
-			WithCheck(func(ctx context.Context) error {
-				serverAuthorizationsWOServer := []string{}
-
-				for _, serverAuthorization := range resources.ServerAuthorizations {
-					founded := false
-					for _, server := range resources.Servers {
-						selector, err := metav1.LabelSelectorAsSelector(serverAuthorization.Spec.Server.Selector)
-						if err != nil {
-							return err
-						}
-
-						if selector.Matches(labels.Set(server.GetLabels())) {
-							founded = true
-						}
-
-						if serverAuthorization.Spec.Server.Name == server.GetName() {
-							founded = true
-						}
-					}
-
-					if !founded {
-						serverAuthorizationsWOServer = append(serverAuthorizationsWOServer, fmt.Sprintf("ServerAuthorizarions %s does not apply to any Server", serverAuthorization.GetName()))
-					}
-				}
+func validateCheckOutput(output string) error {
+	if output != tableOutput && output != jsonOutput && output != yamlOutput {
+		return fmt.Errorf("--output currently only supports %s, %s and %s", tableOutput, jsonOutput, yamlOutput)
+	}
+	return nil
+}
 
-				for _, policy := range resources.AuthorizationPolicies {
-					founded := false
-
-					if policy.Spec.TargetRef.Kind == "Namespace" {
-						// at least one Server should exist
-						founded = len(resources.Servers) > 0
-					} else {
-						for _, server := range resources.Servers {
-							if policy.Spec.TargetRef.Kind == k8s.ServerKind && (string(policy.Spec.TargetRef.Name) == server.GetName()) {
-								founded = true
-								break
-							}
-						}
-
-						for _, httpRoute := range resources.HTTPRoutes {
-							if policy.Spec.TargetRef.Kind == k8s.HTTPRouteKind {
-								for _, server := range resources.Servers {
-									for _, targetRef := range httpRoute.Spec.ParentRefs {
-										if *targetRef.Kind == k8s.ServerKind && string(targetRef.Name) == server.GetName() && string(policy.Spec.TargetRef.Name) == httpRoute.GetName() {
-											founded = true
-											break
-										}
-									}
-								}
-							}
-						}
-					}
-
-					if !founded {
-						serverAuthorizationsWOServer = append(serverAuthorizationsWOServer, fmt.Sprintf("Authorization Policy %s does not apply to any Server", policy.GetName()))
-					}
-				}
+// runEasyAuthChecks runs all easyauth checks against resources and prints results in the given format,
+// it returns false if at least one non-warning check failed
+func runEasyAuthChecks(resources *common.K8sResources, namespace, output string) (bool, error) {
+	if output != tableOutput {
+		return printStructuredChecks(resources, output)
+	}
 
-				if len(serverAuthorizationsWOServer) == 0 {
-					return nil
-				}
-				return fmt.Errorf("Obsolete ServerAuthorizations:\n\t%s", strings.Join(serverAuthorizationsWOServer, "\n\t"))
-			}))
+	hc := healthcheck.NewHealthChecker([]healthcheck.CategoryID{}, &healthcheck.Options{
+		ControlPlaneNamespace: controlPlaneNamespace,
+		KubeConfig:            kubeconfigPath,
+		KubeContext:           kubeContext,
+		Impersonate:           impersonate,
+		ImpersonateGroup:      impersonateGroup,
+		APIAddr:               apiAddr,
+		RetryDeadline:         time.Now().Add(600),
+		DataPlaneNamespace:    namespace,
+	})
 
-	checkers = append(checkers,
-		*healthcheck.NewChecker("linkerd-easyauth no obsolete HTTPRoutes").
-			Warning().
-			WithCheck(func(ctx context.Context) error {
-				httpRoutesWithObsoleteTargetRef := []string{}
-
-				for _, httpRoute := range resources.HTTPRoutes {
-					for _, targetRef := range httpRoute.Spec.ParentRefs {
-						founded := false
-
-						if *targetRef.Kind == k8s.ServerKind {
-							for _, server := range resources.Servers {
-								if string(targetRef.Name) == server.GetName() {
-									founded = true
-									break
-								}
-							}
-						}
-
-						if !founded {
-							httpRoutesWithObsoleteTargetRef = append(
-								httpRoutesWithObsoleteTargetRef,
-								fmt.Sprintf("TargetRef %s in HTTPPolicy %s is obsolete (eg. doesn't apply to any Server)",
-									string(targetRef.Name),
-									httpRoute.GetName(),
-								),
-							)
-						}
-					}
-				}
+	hc.AppendCategories(easyAuthCategory(resources))
 
-				if len(httpRoutesWithObsoleteTargetRef) == 0 {
-					return nil
-				}
-				return fmt.Errorf("Some HTTPRoutes have obsolete targetRef:\n\t%s", strings.Join(httpRoutesWithObsoleteTargetRef, "\n\t"))
-			}))
+	success, warning := healthcheck.RunChecks(stdout, stderr, hc, healthcheck.TableOutput)
+	healthcheck.PrintChecksResult(stdout, healthcheck.TableOutput, success, warning)
 
-	checkers = append(checkers,
-		*healthcheck.NewChecker("linkerd-easyauth no ports without Server").
-			Warning().
-			WithCheck(func(ctx context.Context) error {
-				portsWOServers := []string{}
-
-				for _, pod := range resources.Pods.Items {
-					if k8s.IsMeshed(&pod, controlPlaneNamespace) {
-						foundedPorts, err := checkPodsPortsForServer(resources, pod)
-						if err != nil {
-							return err
-						}
-						portsWOServers = append(portsWOServers, foundedPorts...)
-					}
-				}
+	return success, nil
+}
 
-				if len(portsWOServers) == 0 {
-					return nil
-				}
-				return fmt.Errorf("Some pods have ports that are not covered by Server:\n\t%s", strings.Join(portsWOServers, "\n\t"))
-			}))
+// printStructuredChecks runs the checks and prints them as JSON or YAML document,
+// it returns false if at least one non-warning check failed
+func printStructuredChecks(resources *common.K8sResources, output string) (bool, error) {
+	result := checkOutput{
+		Success: true,
+		Categories: []checkCategory{
+			{
+				Name:   string(linkerdEasyAuthExtensionCheck),
+				Checks: []checkResult{},
+			},
+		},
+	}
 
-	checkers = append(checkers,
-		*healthcheck.NewChecker("linkerd-easyauth no obsolete authentications").
-			Warning().
-			WithCheck(func(ctx context.Context) error {
-				var authentications []metav1.Object
-				var obsoleteAuthentications []string
+	for _, check := range common.EasyAuthChecks(controlPlaneNamespace) {
+		res := checkResult{
+			ID:          check.ID,
+			Description: check.Description,
+			Result:      checkSuccess,
+			Severity:    check.Severity,
+		}
 
-				for _, authn := range resources.MeshTLSAuthentications {
-					authentications = append(authentications, authn)
-				}
+		findings, err := check.RunPerNamespace(resources)
+		if err != nil {
+			res.Result = string(check.Severity)
+			res.Error = err.Error()
+		} else if len(findings) > 0 {
+			res.Result = string(check.Severity)
+			res.Error = check.Summary
+			res.Findings = findings
+		}
 
-				for _, authn := range resources.NetworkAuthentications {
-					authentications = append(authentications, authn)
-				}
+		if res.Result == string(common.SeverityError) {
+			result.Success = false
+		}
 
-				for _, authn := range authentications {
-					founded := false
-
-					for _, policy := range resources.AuthorizationPolicies {
-						for _, targetRef := range policy.Spec.RequiredAuthenticationRefs {
-							if string(targetRef.Name) == authn.GetName() {
-								founded = true
-								break
-							}
-						}
-					}
-
-					if !founded {
-						obsoleteAuthentications = append(
-							obsoleteAuthentications,
-							fmt.Sprintf("%s %s is obsolete",
-								strings.Split(reflect.TypeOf(authn).String(), ".")[1],
-								authn.GetName(),
-							),
-						)
-					}
-				}
+		result.Categories[0].Checks = append(result.Categories[0].Checks, res)
+	}
 
-				if len(obsoleteAuthentications) == 0 {
-					return nil
-				}
-				return fmt.Errorf("Some authentications are obsolete (eg. doesn't apply to any policy):\n\t%s", strings.Join(obsoleteAuthentications, "\n\t"))
-			}))
+	var out []byte
+	var err error
+	if output == yamlOutput {
+		out, err = yaml.Marshal(result)
+	} else {
+		out, err = json.MarshalIndent(result, "", "  ")
+		out = append(out, '\n')
+	}
+	if err != nil {
+		return false, err
+	}
 
-	return healthcheck.NewCategory(linkerdEasyAuthExtensionCheck, checkers, true)
+	_, err = stdout.Write(out)
+	return result.Success, err
 }
 
-func checkPodsPortsForServer(resources *K8sResources, pod v1.Pod) ([]string, error) {
-	portsWOServers := []string{}
-	foundedPorts := map[int32]bool{}
-	for _, service := range resources.Services.Items {
-		if len(service.Spec.Selector) > 0 && labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
-			for _, svcPort := range service.Spec.Ports {
-				for _, container := range pod.Spec.Containers {
-					for _, podPort := range container.Ports {
-						var matchedPort v1.ContainerPort
-						if svcPort.TargetPort.IntValue() > 0 {
-							if int(podPort.ContainerPort) == svcPort.TargetPort.IntValue() {
-								matchedPort = podPort
-							}
-						} else {
-							if podPort.Name == svcPort.TargetPort.String() {
-								matchedPort = podPort
-							}
-						}
-
-						if matchedPort.ContainerPort > 0 {
-							founded, err := findServerForPort(resources.Servers, pod, matchedPort)
-							if err != nil {
-								return nil, err
-							}
-
-							if !founded {
-								if !foundedPorts[matchedPort.ContainerPort] {
-									foundedPorts[matchedPort.ContainerPort] = true
-									portsWOServers = append(portsWOServers, fmt.Sprintf("%s -> %s:%d has no Server", pod.Name, container.Name, matchedPort.ContainerPort))
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-	return portsWOServers, nil
-}
+func easyAuthCategory(resources *common.K8sResources) *healthcheck.Category {
+	checkers := []healthcheck.Checker{}
 
-func findServerForPort(servers []*v1beta1.Server, pod v1.Pod, matchedPort v1.ContainerPort) (bool, error) {
-	founded := false
+	for _, check := range common.EasyAuthChecks(controlPlaneNamespace) {
+		check := check
 
-	for _, server := range servers {
-		selector, err := metav1.LabelSelectorAsSelector(server.Spec.PodSelector)
-		if err != nil {
-			return false, err
+		checker := healthcheck.NewChecker(check.Description)
+		if check.Severity == common.SeverityWarning {
+			checker = checker.Warning()
 		}
 
-		if selector.Matches(labels.Set(pod.Labels)) {
-			if server.Spec.Port.IntValue() > 0 {
-				if int(matchedPort.ContainerPort) == server.Spec.Port.IntValue() {
-					founded = true
+		checkers = append(checkers,
+			*checker.WithCheck(func(ctx context.Context) error {
+				findings, err := check.RunPerNamespace(resources)
+				if err != nil {
+					return err
 				}
-			} else {
-				if matchedPort.Name == server.Spec.Port.String() {
-					founded = true
+
+				if len(findings) == 0 {
+					return nil
 				}
-			}
-		}
+
+				messages := make([]string, 0, len(findings))
+				for _, finding := range findings {
+					messages = append(messages, finding.Message)
+				}
+				return fmt.Errorf("%s:\n\t%s", check.Summary, strings.Join(messages, "\n\t"))
+			}))
 	}
-	return founded, nil
+
+	return healthcheck.NewCategory(linkerdEasyAuthExtensionCheck, checkers, true)
 }
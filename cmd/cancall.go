@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/linkerd/linkerd2/cli/table"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+	"strings"
+)
+
+type canCallOptions struct {
+	namespace              string
+	serviceAccount         string
+	trustDomain            string
+	includeUnauthenticated bool
+	filenames              []string
+}
+
+func newCmdCanCall() *cobra.Command {
+	options := canCallOptions{
+		trustDomain: common.DefaultTrustDomain,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "can-call [flags] [(RESOURCE/NAME | RESOURCE NAME)]",
+		Short: "List Servers that a resource or a service account is allowed to call",
+		Long: `List Servers that a resource or a service account is allowed to call.
+
+Looks for Servers and HTTPRoutes across all namespaces whose policies admit the mesh identity
+of the client, including wildcard identities.`,
+		Example: `  linkerd easyauth can-call deploy/client -n app
+  linkerd easyauth can-call --service-account app/client`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && options.serviceAccount == "" {
+				return errors.New("either a resource or --service-account should be provided")
+			}
+
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), v1.NamespaceAll)
+			if err != nil {
+				return err
+			}
+
+			var client common.Client
+			if options.serviceAccount != "" {
+				parts := strings.SplitN(options.serviceAccount, "/", 2)
+				if len(parts) == 1 {
+					parts = []string{options.namespace, parts[0]}
+				}
+
+				client = common.Client{
+					Namespace:      parts[0],
+					ServiceAccount: parts[1],
+					Identity:       common.ServiceAccountIdentity(parts[1], parts[0], controlPlaneNamespace, options.trustDomain),
+				}
+			} else {
+				resource := args[0]
+				if len(args) == 2 {
+					resource = args[0] + "/" + args[1]
+				}
+
+				pods, err := common.PodsForResource(resources, options.namespace, resource)
+				if err != nil {
+					return err
+				}
+				if len(pods) == 0 {
+					return fmt.Errorf("no pods found for %s", resource)
+				}
+
+				client = common.ClientForPods(pods, controlPlaneNamespace, options.trustDomain)
+				if client.Identity == "" {
+					fmt.Fprintf(stderr, "%s is not meshed, only policies for unauthenticated clients could admit it\n", resource)
+				}
+			}
+
+			rules, err := common.OutboundRulesForClient(resources, client, options.includeUnauthenticated)
+			if err != nil {
+				return err
+			}
+
+			rows := make([]table.Row, 0)
+			for _, rule := range rules {
+				route := "*"
+				if rule.Route != nil {
+					route = rule.Route.Name
+				}
+
+				rows = append(rows, table.Row{
+					rule.Server.Namespace,
+					rule.Server.Name,
+					rule.Server.Spec.Port.String(),
+					route,
+					rule.Policy.Kind + "/" + rule.Policy.Name,
+					rule.AdmittedBy,
+				})
+			}
+
+			cols := []table.Column{
+				{Header: "NAMESPACE", Width: 9, Flexible: true},
+				{Header: "SERVER", Width: 6, Flexible: true},
+				{Header: "PORT", Width: 4, Flexible: true},
+				{Header: "ROUTE", Width: 5, Flexible: true},
+				{Header: "POLICY", Width: 6, Flexible: true},
+				{Header: "ADMITTED_BY", Width: 11, Flexible: true, LeftAlign: true},
+			}
+
+			table := table.NewTable(cols, rows)
+			table.Render(stdout)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace of the resource or the service account")
+	cmd.Flags().StringVar(&options.serviceAccount, "service-account", options.serviceAccount, "The client service account in form of [namespace/]name")
+	cmd.Flags().StringVar(&options.trustDomain, "identity-trust-domain", options.trustDomain, "Trust domain of Linkerd identities")
+	cmd.Flags().BoolVar(&options.includeUnauthenticated, "include-unauthenticated", options.includeUnauthenticated, "Also list Servers that admit unauthenticated clients")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
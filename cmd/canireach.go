@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+)
+
+type canIReachOptions struct {
+	namespace            string
+	from                 string
+	fromNamespace        string
+	to                   string
+	toNamespace          string
+	port                 string
+	path                 string
+	method               string
+	trustDomain          string
+	clusterDefaultPolicy string
+	clusterNetworks      []string
+	filenames            []string
+}
+
+func newCmdCanIReach() *cobra.Command {
+	options := canIReachOptions{
+		path:                 "/",
+		method:               "GET",
+		trustDomain:          common.DefaultTrustDomain,
+		clusterDefaultPolicy: common.AllUnauthenticatedPolicy,
+		clusterNetworks:      common.DefaultClusterNetworks,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "can-i-reach [flags]",
+		Short: "Checks if one workload is allowed to call another one",
+		Long: `Checks if one workload is allowed to call another one.
+
+Computes the client mTLS identity from its ServiceAccount, evaluates Servers, HTTPRoutes,
+AuthorizationPolicies, ServerAuthorizations and authentications of the target and prints
+ALLOW or DENY with the chain of objects that decided it.`,
+		Example: "  linkerd easyauth can-i-reach --from deploy/client --from-namespace ns1 --to deploy/server --to-namespace ns2 --port 8080 --path /api --method GET",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.from == "" || options.to == "" || options.port == "" {
+				return errors.New("--from, --to and --port should be provided")
+			}
+
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+			if options.fromNamespace == "" {
+				options.fromNamespace = options.namespace
+			}
+			if options.toNamespace == "" {
+				options.toNamespace = options.namespace
+			}
+
+			namespace := options.toNamespace
+			if options.fromNamespace != options.toNamespace {
+				namespace = v1.NamespaceAll
+			}
+
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), namespace)
+			if err != nil {
+				return err
+			}
+
+			clientPods, err := common.PodsForResource(resources, options.fromNamespace, options.from)
+			if err != nil {
+				return err
+			}
+			if len(clientPods) == 0 {
+				return fmt.Errorf("no pods found for %s", options.from)
+			}
+
+			serverPods, err := common.PodsForResource(resources, options.toNamespace, options.to)
+			if err != nil {
+				return err
+			}
+			if len(serverPods) == 0 {
+				return fmt.Errorf("no pods found for %s", options.to)
+			}
+
+			client := common.ClientForPods(clientPods, controlPlaneNamespace, options.trustDomain)
+
+			decision, err := common.EvaluateAccess(resources, common.AccessRequest{
+				Client:               client,
+				Pod:                  serverPods[0],
+				Port:                 options.port,
+				Path:                 options.path,
+				Method:               options.method,
+				ClusterDefaultPolicy: options.clusterDefaultPolicy,
+				ClusterNetworks:      options.clusterNetworks,
+			})
+			if err != nil {
+				return err
+			}
+
+			if decision.Allowed {
+				fmt.Fprintln(stdout, "ALLOW")
+			} else {
+				fmt.Fprintln(stdout, "DENY")
+			}
+
+			fmt.Fprintf(stdout, "\tclient: %s/%s", client.Namespace, client.ServiceAccount)
+			if client.Identity != "" {
+				fmt.Fprintf(stdout, " (%s)", client.Identity)
+			}
+			fmt.Fprintln(stdout)
+
+			for _, step := range decision.Chain {
+				fmt.Fprintf(stdout, "\t%s: %s\n", step.Object, step.Detail)
+			}
+
+			for _, rejection := range decision.Rejections {
+				fmt.Fprintf(stdout, "\t* %s\n", rejection)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace of both workloads unless --from-namespace or --to-namespace are set")
+	cmd.Flags().StringVar(&options.from, "from", options.from, "The client resource, eg. deploy/client")
+	cmd.Flags().StringVar(&options.fromNamespace, "from-namespace", options.fromNamespace, "The namespace of the client")
+	cmd.Flags().StringVar(&options.to, "to", options.to, "The server resource, eg. deploy/server")
+	cmd.Flags().StringVar(&options.toNamespace, "to-namespace", options.toNamespace, "The namespace of the server")
+	cmd.Flags().StringVar(&options.port, "port", options.port, "The server port (name or number)")
+	cmd.Flags().StringVar(&options.path, "path", options.path, "The request path for HTTPRoute matching")
+	cmd.Flags().StringVar(&options.method, "method", options.method, "The request method for HTTPRoute matching")
+	cmd.Flags().StringVar(&options.trustDomain, "identity-trust-domain", options.trustDomain, "Trust domain of Linkerd identities")
+	cmd.Flags().StringVar(&options.clusterDefaultPolicy, "default-inbound-policy", options.clusterDefaultPolicy, "Default inbound policy of the Linkerd installation")
+	cmd.Flags().StringSliceVar(&options.clusterNetworks, "cluster-networks", options.clusterNetworks, "Cluster networks of the Linkerd installation")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace", "from-namespace", "to-namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
@@ -43,6 +43,14 @@ func NewEasyAuthCmd() *cobra.Command {
 	easyAuthCmd.AddCommand(newCmdList())
 	easyAuthCmd.AddCommand(newCmdAuthCheck())
 	easyAuthCmd.AddCommand(newCmdAuthz())
+	easyAuthCmd.AddCommand(newCmdLint())
+	easyAuthCmd.AddCommand(newCmdWhoCanCall())
+	easyAuthCmd.AddCommand(newCmdCanIReach())
+	easyAuthCmd.AddCommand(newCmdCanCall())
+	easyAuthCmd.AddCommand(newCmdGraph())
+	easyAuthCmd.AddCommand(newCmdGenerate())
+	easyAuthCmd.AddCommand(newCmdMigrate())
+	easyAuthCmd.AddCommand(newCmdPrune())
 
 	easyAuthCmd.PersistentFlags().StringVarP(&controlPlaneNamespace, "linkerd-namespace", "L", defaultLinkerdNamespace, "Namespace in which Linkerd is installed")
 	easyAuthCmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file to use for CLI requests")
@@ -1,77 +1,118 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/linkerd/linkerd2/cli/table"
 	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
-	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	common "linkerd-easyauth/pkg"
 	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
 )
 
+const wideOutput = "wide"
+
 type authzOptions struct {
-	namespace string
+	namespace     string
+	allNamespaces bool
+	selector      string
+	output        string
+	filenames     []string
 }
 
 func newCmdAuthz() *cobra.Command {
-	var options authzOptions
+	options := authzOptions{
+		output: tableOutput,
+	}
 
 	cmd := &cobra.Command{
-		Use:   "authz [flags]",
+		Use:   "authz [flags] [(RESOURCE/NAME | RESOURCE NAME)]",
 		Short: "List server authorizations for a resource (fast implementation)",
-		Long:  "List server authorizations for a resource (fast implementation).",
-		Args:  cobra.RangeArgs(1, 2),
+		Long: `List server authorizations for a resource (fast implementation).
+
+With --all-namespaces or --selector authorizations are listed for every deployment, statefulset,
+daemonset and standalone pod that matches the selector.`,
+		Example: `  linkerd easyauth authz deploy/web -n app
+  linkerd easyauth authz -A -l app=web`,
+		Args: cobra.RangeArgs(0, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.output != tableOutput && options.output != wideOutput && options.output != jsonOutput && options.output != yamlOutput {
+				return fmt.Errorf("--output currently only supports %s, %s, %s and %s", tableOutput, wideOutput, jsonOutput, yamlOutput)
+			}
+
 			if options.namespace == "" {
 				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
 			}
 
-			var resource string
-			if len(args) == 1 {
-				resource = args[0]
-			} else if len(args) == 2 {
-				resource = args[0] + "/" + args[1]
+			multiple := options.allNamespaces || options.selector != ""
+			if multiple && len(args) > 0 {
+				return errors.New("a resource can't be used together with --all-namespaces or --selector")
+			}
+			if !multiple && len(args) == 0 {
+				return errors.New("a resource, --all-namespaces or --selector should be provided")
 			}
 
-			rows := make([]table.Row, 0)
+			if options.allNamespaces {
+				options.namespace = v1.NamespaceAll
+			}
 
-			prefetched, err := FetchK8sResources(cmd.Context(), options.namespace)
+			prefetched, err := newResourceSource(options.filenames).Fetch(cmd.Context(), options.namespace)
 			if err != nil {
 				return err
 			}
 
-			k8sAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext, impersonate, impersonateGroup, 0)
+			if multiple {
+				selector, err := labels.Parse(options.selector)
+				if err != nil {
+					return fmt.Errorf("invalid selector %q: %w", options.selector, err)
+				}
 
-			authzs, err := common.AuthorizationsForResource(cmd.Context(), k8sAPI, prefetched.AuthorizationPolicies, prefetched.HTTPRoutes, prefetched.ServerAuthorizations, prefetched.Servers, options.namespace, resource)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to get serverauthorization resources: %s\n", err)
-				os.Exit(1)
-			}
+				workloads := common.WorkloadsForSelector(prefetched, options.namespace, selector)
+
+				results, err := common.AuthorizationDetailsForWorkloads(prefetched, workloads)
+				if err != nil {
+					fmt.Fprintf(stderr, "Failed to get serverauthorization resources: %s\n", err)
+					os.Exit(1)
+				}
 
-			for _, authz := range authzs {
-				route := "*"
-				if authz.Route != "" {
-					route = authz.Route
+				if options.output == tableOutput || options.output == wideOutput {
+					renderAuthzTable(results, true, options.output == wideOutput)
+					return nil
 				}
-				rows = append(rows, table.Row{route, authz.Server, authz.ServerAuthorization, authz.AuthorizationPolicy})
+				return printAuthzStructured(results, options.output)
 			}
 
-			cols := []table.Column{
-				{Header: "ROUTE", Width: 10, Flexible: true},
-				{Header: "SERVER", Width: 10, Flexible: true},
-				{Header: "SERVER_AUTHORIZATION", Width: 21, Flexible: true},
-				{Header: "AUTHORIZATION_POLICY", Width: 21, Flexible: true},
+			var resource string
+			if len(args) == 1 {
+				resource = args[0]
+			} else if len(args) == 2 {
+				resource = args[0] + "/" + args[1]
 			}
 
-			table := table.NewTable(cols, rows)
-			table.Render(os.Stdout)
+			authzs, err := common.AuthorizationDetailsForResource(prefetched, options.namespace, resource)
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to get serverauthorization resources: %s\n", err)
+				os.Exit(1)
+			}
 
-			return nil
+			if options.output == tableOutput || options.output == wideOutput {
+				renderAuthzTable([]common.WorkloadAuthorizations{{Authorizations: authzs}}, false, options.output == wideOutput)
+				return nil
+			}
+			return printAuthzStructured(authzs, options.output)
 		},
 	}
 
 	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to list pods in")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, list authorizations of all workloads across all namespaces")
+	cmd.Flags().StringVarP(&options.selector, "selector", "l", options.selector, "Selector (label query) to filter workloads on, supports '=', '==', and '!='")
+	cmd.Flags().StringVarP(&options.output, "output", "o", options.output, fmt.Sprintf("Output format. One of: %s, %s, %s, %s", tableOutput, wideOutput, jsonOutput, yamlOutput))
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
 
 	pkgcmd.ConfigureNamespaceFlagCompletion(
 		cmd, []string{"namespace"},
@@ -79,3 +120,129 @@ func newCmdAuthz() *cobra.Command {
 
 	return cmd
 }
+
+func printAuthzStructured(v interface{}, output string) error {
+	var out []byte
+	var err error
+	if output == yamlOutput {
+		out, err = yaml.Marshal(v)
+	} else {
+		out, err = json.MarshalIndent(v, "", "  ")
+		out = append(out, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(out)
+	return err
+}
+
+func renderAuthzTable(workloads []common.WorkloadAuthorizations, showWorkload bool, wide bool) {
+	rows := make([]table.Row, 0)
+
+	for _, workload := range workloads {
+		for _, authz := range workload.Authorizations {
+			rows = append(rows, authzRow(workload.Workload, authz, showWorkload, wide))
+		}
+	}
+
+	cols := []table.Column{}
+	if showWorkload {
+		cols = append(cols,
+			table.Column{Header: "NAMESPACE", Width: 9, Flexible: true},
+			table.Column{Header: "RESOURCE", Width: 8, Flexible: true},
+		)
+	}
+
+	cols = append(cols,
+		table.Column{Header: "ROUTE", Width: 10, Flexible: true},
+		table.Column{Header: "SERVER", Width: 10, Flexible: true},
+		table.Column{Header: "SERVER_AUTHORIZATION", Width: 21, Flexible: true},
+		table.Column{Header: "AUTHORIZATION_POLICY", Width: 21, Flexible: true},
+	)
+
+	if wide {
+		cols = append(cols,
+			table.Column{Header: "PORT", Width: 4, Flexible: true},
+			table.Column{Header: "PROTOCOL", Width: 8, Flexible: true},
+			table.Column{Header: "PODS", Width: 4, Flexible: true},
+			table.Column{Header: "MATCHES", Width: 7, Flexible: true},
+			table.Column{Header: "AUTHN_KINDS", Width: 11, Flexible: true},
+			table.Column{Header: "CLIENTS", Width: 7, Flexible: true},
+			table.Column{Header: "NETWORKS", Width: 8, Flexible: true, LeftAlign: true},
+		)
+	}
+
+	table := table.NewTable(cols, rows)
+	table.Render(stdout)
+}
+
+func authzRow(workload common.ObjectReference, authz common.AuthorizationDetails, showWorkload bool, wide bool) table.Row {
+	route := "*"
+	if authz.Route != "" {
+		route = authz.Route
+	}
+
+	row := table.Row{}
+	if showWorkload {
+		row = append(row, workload.Namespace, strings.ToLower(workload.Kind)+"/"+workload.Name)
+	}
+	row = append(row, route, authz.Server, authz.ServerAuthorization, authz.AuthorizationPolicy)
+
+	if !wide {
+		return row
+	}
+
+	matches := make([]string, 0, len(authz.RouteMatches))
+	for _, match := range authz.RouteMatches {
+		matches = append(matches, common.RouteMatchString(match))
+	}
+
+	// clients of each required authentication are joined with "+", a client must match all of them
+	var clients, networks []string
+	for i, group := range authz.Clients.Groups() {
+		var groupClients []string
+		if i == 0 && group.Unauthenticated {
+			groupClients = append(groupClients, "unauthenticated")
+		}
+		groupClients = append(groupClients, group.Identities...)
+		groupClients = append(groupClients, group.ServiceAccounts...)
+		groupClients = append(groupClients, group.Namespaces...)
+		if len(groupClients) > 0 {
+			clients = append(clients, strings.Join(groupClients, ","))
+		}
+
+		groupNetworks := make([]string, 0, len(group.Networks))
+		for _, network := range group.Networks {
+			groupNetworks = append(groupNetworks, network.String())
+		}
+		if len(groupNetworks) > 0 {
+			networks = append(networks, strings.Join(groupNetworks, ","))
+		}
+	}
+
+	return append(row,
+		authz.Port,
+		authz.ProxyProtocol,
+		joinOrDash(authz.Pods),
+		joinOrDash(matches),
+		joinOrDash(authz.AuthenticationKinds),
+		joinGroupsOrDash(clients),
+		joinGroupsOrDash(networks),
+	)
+}
+
+func joinGroupsOrDash(groups []string) string {
+	if len(groups) == 0 {
+		return "-"
+	}
+	return strings.Join(groups, "+")
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
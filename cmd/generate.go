@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/spf13/cobra"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+)
+
+type generatePoliciesOptions struct {
+	namespace   string
+	fromMetrics string
+	outputDir   string
+}
+
+type generateServersOptions struct {
+	namespace     string
+	allNamespaces bool
+	outputDir     string
+	filenames     []string
+}
+
+func newCmdGenerate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate easyauth resources",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newCmdGenerateServers())
+	cmd.AddCommand(newCmdGeneratePolicies())
+
+	return cmd
+}
+
+func newCmdGenerateServers() *cobra.Command {
+	var options generateServersOptions
+
+	cmd := &cobra.Command{
+		Use:   "servers [flags]",
+		Short: "Generate Servers for pod ports that are not covered by any Server",
+		Long: `Generate Servers for pod ports that are not covered by any Server.
+
+Servers are generated for the same ports that "authcheck" reports as ports without Server. A Server is named
+after the owning workload and the port, and selects pods by the selector of the workload.`,
+		Example: `  linkerd easyauth generate servers -n app | kubectl apply -f -
+  linkerd easyauth generate servers -A --output-dir ./servers`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+			if options.allNamespaces {
+				options.namespace = v1.NamespaceAll
+			}
+
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), options.namespace)
+			if err != nil {
+				return err
+			}
+
+			servers, err := common.GenerateServers(resources, controlPlaneNamespace)
+			if err != nil {
+				return err
+			}
+
+			if len(servers) == 0 {
+				fmt.Fprintln(stderr, "All ports are covered by Servers")
+				return nil
+			}
+
+			if options.outputDir != "" {
+				if err := os.MkdirAll(options.outputDir, 0755); err != nil {
+					return err
+				}
+			}
+
+			for _, srv := range servers {
+				out, err := yaml.Marshal(srv)
+				if err != nil {
+					return err
+				}
+
+				if options.outputDir == "" {
+					fmt.Fprintf(stdout, "---\n%s", out)
+					continue
+				}
+
+				path := filepath.Join(options.outputDir, fmt.Sprintf("%s-%s.yaml", srv.Namespace, srv.Name))
+				if err := os.WriteFile(path, out, 0644); err != nil {
+					return err
+				}
+				fmt.Fprintf(stderr, "%s written\n", path)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to generate Servers for")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, generate Servers across all namespaces")
+	cmd.Flags().StringVar(&options.outputDir, "output-dir", options.outputDir, "Write every Server to its own file in the directory instead of stdout")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
+
+func newCmdGeneratePolicies() *cobra.Command {
+	var options generatePoliciesOptions
+
+	cmd := &cobra.Command{
+		Use:   "policies [flags]",
+		Short: "Generate AuthorizationPolicies from the observed traffic",
+		Long: `Generate AuthorizationPolicies from the observed traffic.
+
+Reads Linkerd proxy metrics (inbound_http_authz_allow_total, inbound_tcp_authz_allow_total, request_total and tcp_open_total)
+exported from Prometheus in text exposition format, and generates an AuthorizationPolicy with a MeshTLSAuthentication
+for every Server that admits exactly the client identities that were seen in the metrics. Denied clients are ignored.`,
+		Example: `  curl -s -G http://prometheus:9090/federate --data-urlencode 'match[]={__name__=~"inbound_(http|tcp)_authz_allow_total"}' > linkerd.prom
+  linkerd easyauth generate policies --from-metrics linkerd.prom -n app`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.fromMetrics == "" {
+				return errors.New("--from-metrics should be provided")
+			}
+
+			var in io.Reader = os.Stdin
+			if options.fromMetrics != common.StdinManifest {
+				file, err := os.Open(options.fromMetrics)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				in = file
+			}
+
+			traffic, err := common.ObservedTrafficFromMetrics(in)
+			if err != nil {
+				return err
+			}
+
+			var filtered []common.ObservedTraffic
+			for _, observed := range traffic {
+				if options.namespace != "" && observed.Server.Namespace != options.namespace {
+					continue
+				}
+
+				if observed.UnauthenticatedRequests > 0 {
+					fmt.Fprintf(stderr, "%s received %.0f requests from clients without mesh identity, they will be denied by generated policies\n", observed.Server, observed.UnauthenticatedRequests)
+				}
+				filtered = append(filtered, observed)
+			}
+
+			generated := common.GeneratePolicies(filtered)
+			if len(generated) == 0 {
+				fmt.Fprintln(stderr, "No traffic from meshed clients to Servers found in metrics")
+				return nil
+			}
+
+			if options.outputDir != "" {
+				if err := os.MkdirAll(options.outputDir, 0755); err != nil {
+					return err
+				}
+			}
+
+			for _, gen := range generated {
+				authn, err := yaml.Marshal(gen.Authentication)
+				if err != nil {
+					return err
+				}
+
+				authzPolicy, err := yaml.Marshal(gen.Policy)
+				if err != nil {
+					return err
+				}
+
+				out := fmt.Sprintf("---\n%s---\n%s", authn, authzPolicy)
+
+				if options.outputDir == "" {
+					fmt.Fprint(stdout, out)
+					continue
+				}
+
+				path := filepath.Join(options.outputDir, fmt.Sprintf("%s-%s.yaml", gen.Policy.Namespace, gen.Policy.Name))
+				if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+					return err
+				}
+				fmt.Fprintf(stderr, "%s written\n", path)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "Generate policies for Servers of the namespace only, all namespaces by default")
+	cmd.Flags().StringVar(&options.fromMetrics, "from-metrics", options.fromMetrics, "File with metrics in Prometheus text format, use - to read from stdin")
+	cmd.Flags().StringVar(&options.outputDir, "output-dir", options.outputDir, "Write policies of every Server to its own file in the directory instead of stdout")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/spf13/cobra"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+	"strconv"
+	"strings"
+)
+
+// graph formats besides jsonOutput
+const (
+	dotFormat     = "dot"
+	mermaidFormat = "mermaid"
+)
+
+var dotShapes = map[string]string{
+	common.PodKind:                   "ellipse",
+	common.ServerKind:                "box",
+	common.HTTPRouteKind:             "box",
+	common.ServerAuthorizationKind:   "hexagon",
+	common.AuthorizationPolicyKind:   "hexagon",
+	common.MeshTLSAuthenticationKind: "octagon",
+	common.NetworkAuthenticationKind: "octagon",
+}
+
+type graphOptions struct {
+	namespace     string
+	allNamespaces bool
+	format        string
+	filenames     []string
+}
+
+func newCmdGraph() *cobra.Command {
+	options := graphOptions{
+		format: dotFormat,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "graph [flags]",
+		Short: "Print the authorization graph",
+		Long: `Print the authorization graph.
+
+Nodes are pods, Servers, HTTPRoutes, policies, authentications and clients,
+edges are labelled by the field that references the target.`,
+		Example: `  linkerd easyauth graph -n app | dot -Tsvg > app.svg
+  linkerd easyauth graph -n app --format mermaid`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.format != dotFormat && options.format != mermaidFormat && options.format != jsonOutput {
+				return fmt.Errorf("--format currently only supports %s, %s and %s", dotFormat, mermaidFormat, jsonOutput)
+			}
+
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+			if options.allNamespaces {
+				options.namespace = v1.NamespaceAll
+			}
+
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), options.namespace)
+			if err != nil {
+				return err
+			}
+
+			graph, err := common.BuildGraph(resources)
+			if err != nil {
+				return err
+			}
+
+			switch options.format {
+			case mermaidFormat:
+				return printMermaidGraph(stdout, graph)
+			case jsonOutput:
+				out, err := json.MarshalIndent(graph, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(stdout, string(out))
+				return err
+			default:
+				return printDotGraph(stdout, graph)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to draw the graph for")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, draw the graph across all namespaces")
+	cmd.Flags().StringVar(&options.format, "format", options.format, fmt.Sprintf("Output format. One of: %s, %s, %s", dotFormat, mermaidFormat, jsonOutput))
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
+
+func graphNodeLabel(node common.GraphNode) string {
+	name := node.Name
+	if node.Namespace != "" {
+		name = node.Namespace + "/" + node.Name
+	}
+	if node.Missing {
+		name += " (missing)"
+	}
+	return node.Kind + "\n" + name
+}
+
+func printDotGraph(w io.Writer, graph *common.Graph) error {
+	var b strings.Builder
+
+	b.WriteString("digraph easyauth {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	for _, node := range graph.Nodes {
+		shape, ok := dotShapes[node.Kind]
+		if !ok {
+			shape = "note"
+		}
+
+		style := "solid"
+		if node.Missing {
+			style = "dashed"
+		}
+
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s, style=%s];\n", strconv.Quote(node.ID), strconv.Quote(graphNodeLabel(node)), shape, style)
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.Label))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func printMermaidGraph(w io.Writer, graph *common.Graph) error {
+	var b strings.Builder
+
+	// mermaid ids can't contain slashes and dots, so nodes are numbered in their sorted order
+	ids := make(map[string]string, len(graph.Nodes))
+
+	b.WriteString("flowchart LR\n")
+
+	for i, node := range graph.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.ID] = id
+
+		label := strings.ReplaceAll(graphNodeLabel(node), "\"", "#quot;")
+		label = strings.ReplaceAll(label, "\n", "<br/>")
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", id, label)
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[edge.From], edge.Label, ids[edge.To])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
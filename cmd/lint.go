@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+	"os"
+)
+
+type lintOptions struct {
+	filenames []string
+	namespace string
+	output    string
+}
+
+func newCmdLint() *cobra.Command {
+	var options lintOptions
+
+	cmd := &cobra.Command{
+		Use:   "lint [flags]",
+		Short: "Runs authcheck checks against manifests without a cluster",
+		Long: `Runs authcheck checks against manifests without a cluster.
+
+Manifests are read from files, directories or stdin, for example:
+
+  helm template my-chart | linkerd easyauth lint -f -`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(options.filenames) == 0 {
+				return errors.New("at least one manifest should be provided with --filename")
+			}
+
+			if err := validateCheckOutput(options.output); err != nil {
+				return err
+			}
+
+			source := &common.ManifestSource{
+				Paths:                 options.filenames,
+				ControlPlaneNamespace: controlPlaneNamespace,
+				DefaultNamespace:      options.namespace,
+			}
+
+			// lint all objects, the namespace is used for objects without one only
+			resources, err := source.Fetch(cmd.Context(), v1.NamespaceAll)
+			if err != nil {
+				return fmt.Errorf("failed to load manifests: %w", err)
+			}
+
+			success, err := runEasyAuthChecks(resources, options.namespace, options.output)
+			if err != nil {
+				return err
+			}
+
+			if !success {
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Manifest file or directory to lint, use - to read from stdin")
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", v1.NamespaceDefault, "The namespace for objects without one")
+	cmd.Flags().StringVarP(&options.output, "output", "o", tableOutput, fmt.Sprintf("Output format. One of: %s, %s, %s", tableOutput, jsonOutput, yamlOutput))
+
+	return cmd
+}
@@ -15,6 +15,7 @@ import (
 type listOptions struct {
 	namespace     string
 	allNamespaces bool
+	filenames     []string
 }
 
 func newCmdList() *cobra.Command {
@@ -25,30 +26,57 @@ func newCmdList() *cobra.Command {
 		Short: "Lists which pods use easyauth configuration",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			k8sAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext, impersonate, impersonateGroup, 0)
-			if err != nil {
-				return err
-			}
+			var pods []v1.Pod
+			isEnabled := labels.IsEasyAuthEnabled
+			missingTitle := "Pods missing easyAuth configuration (restart these pods to enable easyAuth):"
 
-			if options.namespace == "" {
-				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
-			}
-			if options.allNamespaces {
-				options.namespace = v1.NamespaceAll
-			}
+			if len(options.filenames) > 0 {
+				source := &labels.ManifestSource{
+					Paths:                 options.filenames,
+					ControlPlaneNamespace: controlPlaneNamespace,
+					DefaultNamespace:      options.namespace,
+				}
 
-			pods, err := k8sAPI.CoreV1().Pods(options.namespace).List(cmd.Context(), metav1.ListOptions{})
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
+				// list pods of all namespaces in manifests, the namespace is used for objects without one only
+				resources, err := source.Fetch(cmd.Context(), v1.NamespaceAll)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				pods = resources.Pods.Items
+
+				// manifests are not mutated by the injector yet, it marks meshed pods unless they opt out
+				isEnabled = func(pod *v1.Pod) bool {
+					return labels.IsEasyAuthEnabled(pod) || !labels.IsEasyAuthInjectDisabled(pod, resources.Namespace(pod.Namespace))
+				}
+				missingTitle = fmt.Sprintf("Pods opted out of easyAuth (%s: %s):", labels.EasyAuthInjectAnnotation, labels.EasyAuthInjectDisabled)
+			} else {
+				k8sAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext, impersonate, impersonateGroup, 0)
+				if err != nil {
+					return err
+				}
+
+				if options.namespace == "" {
+					options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+				}
+				if options.allNamespaces {
+					options.namespace = v1.NamespaceAll
+				}
+
+				podList, err := k8sAPI.CoreV1().Pods(options.namespace).List(cmd.Context(), metav1.ListOptions{})
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+				pods = podList.Items
 			}
 
 			var easyAuthEnabled, easyAuthNotEnabled []v1.Pod
 
-			for _, pod := range pods.Items {
+			for _, pod := range pods {
 				pod := pod
 				if pkgK8s.IsMeshed(&pod, controlPlaneNamespace) {
-					if labels.IsEasyAuthEnabled(&pod) {
+					if isEnabled(&pod) {
 						easyAuthEnabled = append(easyAuthEnabled, pod)
 					} else {
 						easyAuthNotEnabled = append(easyAuthNotEnabled, pod)
@@ -64,7 +92,7 @@ func newCmdList() *cobra.Command {
 			}
 
 			if len(easyAuthNotEnabled) > 0 {
-				fmt.Println("Pods missing easyAuth configuration (restart these pods to enable easyAuth):")
+				fmt.Println(missingTitle)
 				for _, pod := range easyAuthNotEnabled {
 					fmt.Printf("\t* %s/%s\n", pod.Namespace, pod.Name)
 				}
@@ -78,8 +106,9 @@ func newCmdList() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to list pods in")
-	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, list pods across all namespaces")
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to list pods in, with --filename the namespace for objects without one")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, list pods across all namespaces of the cluster")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read pods of all namespaces from manifest files or directories instead of the cluster, use - to read from stdin")
 
 	pkgcmd.ConfigureNamespaceFlagCompletion(
 		cmd, []string{"namespace"},
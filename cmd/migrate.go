@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+type migrateOptions struct {
+	namespace     string
+	allNamespaces bool
+	deleteList    string
+	filenames     []string
+}
+
+func newCmdMigrate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate deprecated resources",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newCmdMigrateServerAuthorizations())
+
+	return cmd
+}
+
+func newCmdMigrateServerAuthorizations() *cobra.Command {
+	var options migrateOptions
+
+	cmd := &cobra.Command{
+		Use:   "serverauthorizations [flags]",
+		Short: "Convert ServerAuthorizations to AuthorizationPolicies",
+		Long: `Convert ServerAuthorizations to AuthorizationPolicies.
+
+Every ServerAuthorization is converted to an AuthorizationPolicy per selected Server with MeshTLSAuthentication
+and NetworkAuthentication for its clients. Original ServerAuthorizations are not changed, use --delete-list
+to get the list of them that can be deleted after the new resources are applied.`,
+		Example: `  linkerd easyauth migrate serverauthorizations -n app --delete-list saz-delete.yaml > policies.yaml
+  kubectl apply -f policies.yaml && kubectl delete -f saz-delete.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+			if options.allNamespaces {
+				options.namespace = v1.NamespaceAll
+			}
+
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), options.namespace)
+			if err != nil {
+				return err
+			}
+
+			migration, err := common.MigrateServerAuthorizations(resources)
+			if err != nil {
+				return err
+			}
+
+			for _, warning := range migration.Warnings {
+				fmt.Fprintf(stderr, "Warning: %s\n", warning)
+			}
+
+			var objects []interface{}
+			for _, obj := range migration.MeshTLSAuthentications {
+				objects = append(objects, obj)
+			}
+			for _, obj := range migration.NetworkAuthentications {
+				objects = append(objects, obj)
+			}
+			for _, obj := range migration.AuthorizationPolicies {
+				objects = append(objects, obj)
+			}
+
+			for _, obj := range objects {
+				out, err := yaml.Marshal(obj)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(stdout, "---\n%s", out)
+			}
+
+			if options.deleteList != "" {
+				if err := writeDeleteList(options.deleteList, migration.Migrated); err != nil {
+					return err
+				}
+				fmt.Fprintf(stderr, "%d ServerAuthorizations to delete written to %s\n", len(migration.Migrated), options.deleteList)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to migrate ServerAuthorizations in")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, migrate ServerAuthorizations across all namespaces")
+	cmd.Flags().StringVar(&options.deleteList, "delete-list", options.deleteList, "Write manifests of migrated ServerAuthorizations to the file to delete them with kubectl delete -f")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
+
+// writeDeleteList writes minimal manifests of the objects, enough for kubectl delete -f
+func writeDeleteList(path string, objects []common.ObjectReference) error {
+	var b strings.Builder
+
+	for _, obj := range objects {
+		fmt.Fprintf(&b, "---\napiVersion: %s/v1beta1\nkind: %s\nmetadata:\n  name: %s\n  namespace: %s\n", k8s.PolicyAPIGroup, obj.Kind, obj.Name, obj.Namespace)
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	pkgK8s "github.com/linkerd/linkerd2/controller/k8s"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+)
+
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+)
+
+type pruneOptions struct {
+	namespace     string
+	allNamespaces bool
+	dryRun        string
+	confirm       bool
+	filenames     []string
+}
+
+func newCmdPrune() *cobra.Command {
+	options := pruneOptions{
+		dryRun: dryRunNone,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "prune [flags]",
+		Short: "Delete obsolete policy resources",
+		Long: fmt.Sprintf(`Delete obsolete policy resources.
+
+Deletes exactly the objects that are reported by "no obsolete authentications", "no authorization policies
+without Server" and "no obsolete HTTPRoutes" checks. Without --confirm only kubectl delete commands are printed.
+Objects annotated with %s: "true" are never deleted.`, common.PruneExcludeAnnotation),
+		Example: `  linkerd easyauth prune -n app
+  linkerd easyauth prune -n app --dry-run=server --confirm
+  linkerd easyauth prune -n app --confirm`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.dryRun != dryRunNone && options.dryRun != dryRunClient && options.dryRun != dryRunServer {
+				return fmt.Errorf("--dry-run currently only supports %s, %s and %s", dryRunNone, dryRunClient, dryRunServer)
+			}
+
+			if options.confirm && len(options.filenames) > 0 {
+				return errors.New("--confirm can't be used with manifests, objects are deleted from the cluster")
+			}
+
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+			if options.allNamespaces {
+				options.namespace = v1.NamespaceAll
+			}
+
+			source := newResourceSource(options.filenames)
+			if manifests, ok := source.(*common.ManifestSource); ok {
+				manifests.DefaultNamespace = options.namespace
+			}
+
+			// authentications may be required by policies of other namespaces, so all of them are fetched
+			resources, err := source.Fetch(cmd.Context(), v1.NamespaceAll)
+			if err != nil {
+				return err
+			}
+
+			candidates, err := common.PruneCandidates(resources, options.namespace, controlPlaneNamespace)
+			if err != nil {
+				return err
+			}
+
+			var prunable []common.ObjectReference
+			for _, candidate := range candidates {
+				if candidate.Excluded {
+					fmt.Fprintf(stderr, "%s is excluded by %s annotation\n", candidate.Object, common.PruneExcludeAnnotation)
+					continue
+				}
+				prunable = append(prunable, candidate.Object)
+			}
+
+			if len(prunable) == 0 {
+				fmt.Fprintln(stderr, "Nothing to prune")
+				return nil
+			}
+
+			if !options.confirm || options.dryRun == dryRunClient {
+				for _, obj := range prunable {
+					fmt.Fprintf(stdout, "kubectl delete -n %s %s\n", obj.Namespace, common.KubectlResource(obj))
+				}
+				return nil
+			}
+
+			k8sAPI, err := k8s.NewAPI(kubeconfigPath, kubeContext, impersonate, impersonateGroup, 0)
+			if err != nil {
+				return err
+			}
+
+			client, err := pkgK8s.NewL5DCRDClient(k8sAPI.Config)
+			if err != nil {
+				return err
+			}
+
+			suffix := ""
+			if options.dryRun == dryRunServer {
+				suffix = " (server dry run)"
+			}
+
+			for _, obj := range prunable {
+				if err := common.DeletePolicyObject(cmd.Context(), client, obj, options.dryRun == dryRunServer); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", obj, err)
+				}
+				fmt.Fprintf(stdout, "%s deleted%s\n", common.KubectlResource(obj), suffix)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace to prune resources in")
+	cmd.Flags().BoolVarP(&options.allNamespaces, "all-namespaces", "A", options.allNamespaces, "If present, prune resources across all namespaces")
+	cmd.Flags().StringVar(&options.dryRun, "dry-run", options.dryRun, fmt.Sprintf("Must be %s, %s or %s. If client, only print objects that would be deleted; if server, submit server-side dry run deletions", dryRunNone, dryRunClient, dryRunServer))
+	cmd.Flags().BoolVar(&options.confirm, "confirm", options.confirm, "Delete objects instead of printing kubectl delete commands")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories to print kubectl delete commands, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
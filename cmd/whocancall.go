@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/linkerd/linkerd2/cli/table"
+	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+)
+
+type whoCanCallOptions struct {
+	namespace string
+	port      string
+	filenames []string
+}
+
+func newCmdWhoCanCall() *cobra.Command {
+	var options whoCanCallOptions
+
+	cmd := &cobra.Command{
+		Use:   "who-can-call [flags] (RESOURCE/NAME | RESOURCE NAME)",
+		Short: "List clients that are allowed to call a resource",
+		Long: `List clients that are allowed to call a resource.
+
+Resolves Servers of the resource, policies that apply to them (including namespace-wide ones)
+and prints allowed identities, service accounts, namespaces and networks. Clients of rows starting with "and"
+are required as well: a policy with several authentications admits only clients that match each of them.`,
+		Example: "  linkerd easyauth who-can-call deploy/web -n app --port http",
+		Args:    cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.namespace == "" {
+				options.namespace = pkgcmd.GetDefaultNamespace(kubeconfigPath, kubeContext)
+			}
+
+			resource := args[0]
+			if len(args) == 2 {
+				resource = args[0] + "/" + args[1]
+			}
+
+			// authentications and service accounts may be in other namespaces than the resource
+			resources, err := newResourceSource(options.filenames).Fetch(cmd.Context(), v1.NamespaceAll)
+			if err != nil {
+				return err
+			}
+
+			pods, err := common.PodsForResource(resources, options.namespace, resource)
+			if err != nil {
+				return err
+			}
+
+			servers, err := common.ServersForPods(resources, pods, options.port)
+			if err != nil {
+				return err
+			}
+
+			if len(servers) == 0 {
+				fmt.Fprintf(stderr, "No Servers found for %s, the default inbound policy applies\n", resource)
+				return nil
+			}
+
+			rows := make([]table.Row, 0)
+
+			for _, srv := range servers {
+				rules, err := common.AccessRulesForServer(resources, srv)
+				if err != nil {
+					return err
+				}
+
+				if len(rules) == 0 {
+					rows = append(rows, table.Row{srv.Name, srv.Spec.Port.String(), "*", "-", "none", "no policy, all requests are denied"})
+					continue
+				}
+
+				for _, rule := range rules {
+					route := "*"
+					if rule.Route != nil {
+						route = rule.Route.Name
+					}
+
+					for _, client := range clientRows(rule) {
+						rows = append(rows, append(table.Row{srv.Name, srv.Spec.Port.String(), route, rule.Policy.Kind + "/" + rule.Policy.Name}, client...))
+					}
+				}
+			}
+
+			cols := []table.Column{
+				{Header: "SERVER", Width: 6, Flexible: true},
+				{Header: "PORT", Width: 4, Flexible: true},
+				{Header: "ROUTE", Width: 5, Flexible: true},
+				{Header: "POLICY", Width: 6, Flexible: true},
+				{Header: "CLIENT", Width: 6, Flexible: true},
+				{Header: "ALLOWED", Width: 7, Flexible: true, LeftAlign: true},
+			}
+
+			table := table.NewTable(cols, rows)
+			table.Render(stdout)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace, "The namespace of the resource")
+	cmd.Flags().StringVar(&options.port, "port", options.port, "Show only Servers for the port (name or number)")
+	cmd.Flags().StringArrayVarP(&options.filenames, "filename", "f", options.filenames, "Read resources from manifest files or directories instead of the cluster, use - to read from stdin")
+
+	pkgcmd.ConfigureNamespaceFlagCompletion(
+		cmd, []string{"namespace"},
+		kubeconfigPath, impersonate, impersonateGroup, kubeContext)
+
+	return cmd
+}
+
+// clientRows returns client type and value pairs allowed by the rule
+func clientRows(rule common.AccessRule) [][]string {
+	var rows [][]string
+
+	for _, authn := range rule.MissingAuthentications {
+		rows = append(rows, []string{"missing", fmt.Sprintf("%s/%s not found, policy allows nothing", authn.Kind, authn.Name)})
+	}
+
+	if len(rule.MissingAuthentications) > 0 {
+		return rows
+	}
+
+	if rule.Clients.Unauthenticated {
+		rows = append(rows, []string{"unauthenticated", "*"})
+	}
+
+	networkType := "network"
+	if rule.Clients.RequiresMeshTLS() {
+		networkType = "network (and identity)"
+	}
+
+	// clients of further authentications are required too, the policy admits only clients that match each of them
+	for i, clients := range rule.Clients.Groups() {
+		prefix := ""
+		if i > 0 {
+			prefix = "and "
+		}
+
+		for _, identity := range clients.Identities {
+			rows = append(rows, []string{prefix + "identity", identity})
+		}
+
+		for _, sa := range clients.ServiceAccounts {
+			rows = append(rows, []string{prefix + "serviceaccount", sa})
+		}
+
+		for _, ns := range clients.Namespaces {
+			rows = append(rows, []string{prefix + "namespace", ns})
+		}
+
+		for _, network := range clients.Networks {
+			rows = append(rows, []string{prefix + networkType, network.String()})
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,43 @@
+package baseline
+
+import (
+	"context"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	"k8s.io/apimachinery/pkg/runtime"
+	"linkerd-easyauth/controller/reconcile"
+	common "linkerd-easyauth/pkg"
+)
+
+// Reconciler stamps the baseline policy set into namespaces with meshed pods or the opt-in label
+// and removes it from namespaces that opt out
+type Reconciler struct {
+	client                versioned.Interface
+	config                *common.BaselineConfig
+	controlPlaneNamespace string
+}
+
+func NewReconciler(client versioned.Interface, config *common.BaselineConfig, controlPlaneNamespace string) *Reconciler {
+	return &Reconciler{
+		client:                client,
+		config:                config,
+		controlPlaneNamespace: controlPlaneNamespace,
+	}
+}
+
+func (r *Reconciler) Name() string {
+	return common.ManagedByBaselineController
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, resources *common.K8sResources) error {
+	var desired []runtime.Object
+	for _, namespace := range common.BaselineNamespaces(resources, r.config, r.controlPlaneNamespace) {
+		desired = append(desired, common.BaselinePolicies(namespace, r.config, r.controlPlaneNamespace)...)
+	}
+
+	failures, err := reconcile.Sync(ctx, r.client, resources, r.Name(), desired)
+	if err != nil {
+		return err
+	}
+
+	return reconcile.FailuresError(failures)
+}
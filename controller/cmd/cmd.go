@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	pkgK8s "github.com/linkerd/linkerd2/controller/k8s"
+	"github.com/linkerd/linkerd2/pkg/admin"
+	"github.com/linkerd/linkerd2/pkg/flags"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+	"linkerd-easyauth/controller/baseline"
+	"linkerd-easyauth/controller/policies"
+	"linkerd-easyauth/controller/reconcile"
+	"linkerd-easyauth/controller/servers"
+	common "linkerd-easyauth/pkg"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const componentName = "linkerd-easyauth-controller"
+
+func main() {
+	cmd := flag.NewFlagSet("controller", flag.ExitOnError)
+	metricsAddr := cmd.String("metrics-addr", fmt.Sprintf(":%d", 9995),
+		"address to serve scrapable metrics on")
+	kubeconfig := cmd.String("kubeconfig", "", "path to kubeconfig")
+	debounce := cmd.Duration("debounce", 2*time.Second, "time to wait for more changes before the reconciliation")
+	resync := cmd.Duration("resync", 10*time.Minute, "informers resync period")
+	enablePprof := cmd.Bool("enable-pprof", false, "Enable pprof endpoints on the admin server")
+	easyAuthPolicies := cmd.Bool("easyauth-policies", true, "compile EasyAuthPolicies to Linkerd policy resources")
+	controlPlaneNamespace := cmd.String("linkerd-namespace", "linkerd", "namespace in which Linkerd is installed")
+	baselineConfig := cmd.String("baseline-config", "", "path to the baseline policies config, baseline policies are not managed if it is empty")
+	annotatedServers := cmd.Bool("servers", true, fmt.Sprintf("create Servers for workloads with %s annotation", common.ServersAnnotation))
+
+	flags.ConfigureAndParse(cmd, os.Args[1:])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	k8sAPI, err := k8s.NewAPI(*kubeconfig, "", "", []string{}, 0)
+	if err != nil {
+		log.Fatalf("failed to create Kubernetes API client: %s", err)
+	}
+
+	client, err := pkgK8s.NewL5DCRDClient(k8sAPI.Config)
+	if err != nil {
+		log.Fatalf("failed to create Linkerd CRD client: %s", err)
+	}
+
+	source, err := common.NewInformerSource(ctx, k8sAPI, *resync)
+	if err != nil {
+		log.Fatalf("failed to start informers: %s", err)
+	}
+
+	var reconcilers []reconcile.Reconciler
+	if *annotatedServers {
+		reconcilers = append(reconcilers, servers.NewReconciler(client))
+	}
+
+	if *baselineConfig != "" {
+		config, err := common.ReadBaselineConfig(*baselineConfig)
+		if err != nil {
+			log.Fatalf("failed to read baseline config: %s", err)
+		}
+		reconcilers = append(reconcilers, baseline.NewReconciler(client, config, *controlPlaneNamespace))
+	}
+
+	var policiesReconciler *policies.Reconciler
+	if *easyAuthPolicies {
+		dynamicClient, err := dynamic.NewForConfig(k8sAPI.Config)
+		if err != nil {
+			log.Fatalf("failed to create dynamic client: %s", err)
+		}
+
+		policiesReconciler, err = policies.NewReconciler(ctx, client, dynamicClient, *resync)
+		if err != nil {
+			log.Fatalf("failed to start EasyAuthPolicy informer: %s", err)
+		}
+		reconcilers = append(reconcilers, policiesReconciler)
+	}
+
+	adminServer := admin.NewServer(*metricsAddr, *enablePprof)
+	go func() {
+		log.Infof("starting admin server on %s", *metricsAddr)
+		if err := adminServer.ListenAndServe(); err != nil {
+			log.Errorf("failed to start %s admin server: %s", componentName, err)
+		}
+	}()
+
+	loop := reconcile.NewLoop(source, *debounce, reconcilers...)
+	if policiesReconciler != nil {
+		policiesReconciler.OnChange(loop.Changed)
+	}
+	go loop.Run(ctx)
+
+	<-stop
+	log.Infof("shutting down %s", componentName)
+	adminServer.Shutdown(ctx)
+}
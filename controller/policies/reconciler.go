@@ -0,0 +1,231 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"linkerd-easyauth/controller/reconcile"
+	common "linkerd-easyauth/pkg"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	reasonApplied     = "Applied"
+	reasonInvalidSpec = "InvalidSpec"
+	reasonApplyFailed = "ApplyFailed"
+	reasonConflict    = "Conflict"
+)
+
+// Reconciler compiles EasyAuthPolicies to Linkerd policy resources and reports the result in their status
+type Reconciler struct {
+	client        versioned.Interface
+	dynamicClient dynamic.Interface
+	informer      informers.GenericInformer
+}
+
+// NewReconciler starts the EasyAuthPolicy informer and waits for its cache
+func NewReconciler(ctx context.Context, client versioned.Interface, dynamicClient dynamic.Interface, resync time.Duration) (*Reconciler, error) {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, resync)
+
+	r := &Reconciler{
+		client:        client,
+		dynamicClient: dynamicClient,
+		informer:      factory.ForResource(common.EasyAuthPolicyResource),
+	}
+
+	// the informer should be requested before the factory is started
+	synced := r.informer.Informer().HasSynced
+	factory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced) {
+		return nil, errors.New("failed to sync EasyAuthPolicy informer cache")
+	}
+
+	return r, nil
+}
+
+// OnChange calls the handler on every add, update and delete of EasyAuthPolicies
+func (r *Reconciler) OnChange(handler func()) {
+	r.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handler() },
+		UpdateFunc: func(oldObj, newObj interface{}) { handler() },
+		DeleteFunc: func(obj interface{}) { handler() },
+	})
+}
+
+func (r *Reconciler) Name() string {
+	return common.ManagedByEasyAuthPolicyController
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, resources *common.K8sResources) error {
+	objects, err := r.informer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	var desired []runtime.Object
+	conditions := map[types.UID]metav1.Condition{}
+	var policies []*common.EasyAuthPolicy
+
+	for _, obj := range objects {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		p := &common.EasyAuthPolicy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, p); err != nil {
+			log.Errorf("%s: failed to decode %s/%s: %s", r.Name(), u.GetNamespace(), u.GetName(), err)
+			continue
+		}
+		policies = append(policies, p)
+	}
+
+	// on name conflicts between policies objects of the older policy are applied
+	sort.Slice(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	for _, p := range policies {
+		compiled, err := common.CompileEasyAuthPolicy(p)
+		if err != nil {
+			// objects of the last valid spec are kept, so a typo doesn't remove access
+			desired = append(desired, ownedObjects(resources, p.UID)...)
+			conditions[p.UID] = readyCondition(metav1.ConditionFalse, reasonInvalidSpec, err.Error())
+			continue
+		}
+
+		desired = append(desired, compiled...)
+		conditions[p.UID] = readyCondition(metav1.ConditionTrue, reasonApplied, fmt.Sprintf("%d objects are applied", len(compiled)))
+	}
+
+	failures, err := reconcile.Sync(ctx, r.client, resources, r.Name(), desired)
+	if err != nil {
+		return err
+	}
+
+	conflicts := map[types.UID][]string{}
+	applyErrors := map[types.UID][]string{}
+	for _, failure := range failures {
+		if failure.Conflict {
+			conflicts[failure.Owner] = append(conflicts[failure.Owner], failure.Err.Error())
+		} else {
+			applyErrors[failure.Owner] = append(applyErrors[failure.Owner], failure.Err.Error())
+		}
+	}
+
+	for _, p := range policies {
+		condition := conditions[p.UID]
+		if condition.Status == metav1.ConditionTrue {
+			switch {
+			case len(conflicts[p.UID]) > 0:
+				condition = readyCondition(metav1.ConditionFalse, reasonConflict, strings.Join(conflicts[p.UID], "; "))
+			case len(applyErrors[p.UID]) > 0:
+				condition = readyCondition(metav1.ConditionFalse, reasonApplyFailed, strings.Join(applyErrors[p.UID], "; "))
+			}
+		}
+
+		if err := r.updateStatus(ctx, p, condition); err != nil {
+			log.Errorf("%s: failed to update status of %s/%s: %s", r.Name(), p.Namespace, p.Name, err)
+		}
+	}
+
+	return reconcile.FailuresError(failures)
+}
+
+// updateStatus writes the condition if it differs from the current one
+func (r *Reconciler) updateStatus(ctx context.Context, p *common.EasyAuthPolicy, condition metav1.Condition) error {
+	status := common.EasyAuthPolicyStatus{
+		ObservedGeneration: p.Generation,
+		Conditions:         append([]metav1.Condition{}, p.Status.Conditions...),
+	}
+	condition.ObservedGeneration = p.Generation
+	meta.SetStatusCondition(&status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(status, p.Status) {
+		return nil
+	}
+
+	p.Status = status
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+	if err != nil {
+		return err
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion(common.EasyAuthGroupVersion.String())
+	u.SetKind(common.EasyAuthPolicyKind)
+
+	_, err = r.dynamicClient.Resource(common.EasyAuthPolicyResource).Namespace(p.Namespace).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	return err
+}
+
+func readyCondition(status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    common.EasyAuthPolicyReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// ownedObjects returns policy objects that are controlled by the owner
+func ownedObjects(resources *common.K8sResources, owner types.UID) []runtime.Object {
+	var objects []runtime.Object
+
+	owned := func(obj metav1.Object) bool {
+		controller := metav1.GetControllerOf(obj)
+		return controller != nil && controller.UID == owner
+	}
+
+	for _, obj := range resources.Servers {
+		if owned(obj) {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.AuthorizationPolicies {
+		if owned(obj) {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.MeshTLSAuthentications {
+		if owned(obj) {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.NetworkAuthentications {
+		if owned(obj) {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,99 @@
+package reconcile
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	common "linkerd-easyauth/pkg"
+	"time"
+)
+
+var (
+	reconcileErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "easyauth_controller_reconcile_errors_total",
+		Help: "Number of reconciliations that failed",
+	}, []string{"reconciler"})
+
+	lastReconcile = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "easyauth_controller_last_reconcile_timestamp_seconds",
+		Help: "Unix time of the last successful reconciliation",
+	}, []string{"reconciler"})
+)
+
+// Reconciler brings cluster objects to the state that is derived from fetched resources
+type Reconciler interface {
+	Name() string
+	Reconcile(ctx context.Context, resources *common.K8sResources) error
+}
+
+// Loop runs reconcilers on every change of watched resources
+type Loop struct {
+	source      *common.InformerSource
+	debounce    time.Duration
+	reconcilers []Reconciler
+	changes     chan struct{}
+}
+
+// NewLoop creates the loop, changes that come within debounce interval are reconciled together
+func NewLoop(source *common.InformerSource, debounce time.Duration, reconcilers ...Reconciler) *Loop {
+	l := &Loop{
+		source:      source,
+		debounce:    debounce,
+		reconcilers: reconcilers,
+		changes:     make(chan struct{}, 1),
+	}
+
+	source.OnChange(l.Changed)
+
+	return l
+}
+
+// Run reconciles resources on changes until the context is done
+func (l *Loop) Run(ctx context.Context) {
+	l.Changed()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.changes:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.debounce):
+		}
+
+		l.reconcile(ctx)
+	}
+}
+
+// Changed schedules a reconciliation, it is called for changes of resources that the loop doesn't watch itself
+func (l *Loop) Changed() {
+	select {
+	case l.changes <- struct{}{}:
+	default:
+	}
+}
+
+func (l *Loop) reconcile(ctx context.Context) {
+	for _, reconciler := range l.reconcilers {
+		// every reconciler gets its own snapshot, so they can't see each other's modifications
+		resources, err := l.source.Fetch(ctx, "")
+		if err != nil {
+			reconcileErrors.WithLabelValues(reconciler.Name()).Inc()
+			log.Errorf("failed to fetch resources: %s", err)
+			return
+		}
+
+		if err := reconciler.Reconcile(ctx, resources); err != nil {
+			reconcileErrors.WithLabelValues(reconciler.Name()).Inc()
+			log.Errorf("%s: failed to reconcile: %s", reconciler.Name(), err)
+			continue
+		}
+
+		lastReconcile.WithLabelValues(reconciler.Name()).SetToCurrentTime()
+	}
+}
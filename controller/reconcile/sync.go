@@ -0,0 +1,207 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	common "linkerd-easyauth/pkg"
+)
+
+// Failure is a desired object that was not applied or a managed object that was not deleted
+type Failure struct {
+	Object common.ObjectReference
+	// Owner is the controller of the object, it is empty for objects without one
+	Owner types.UID
+	// Conflict is true if the name is taken by an unmanaged object or by a desired object of another owner
+	Conflict bool
+	Err      error
+}
+
+// Sync makes objects labeled as managed by the manager equal to desired objects:
+// missing objects are created, changed objects are updated and objects that are not desired anymore are deleted.
+// The manager owns specs, owner references and desired labels and annotations, other labels, annotations and finalizers
+// of managed objects are kept. Existing objects that are not managed by the manager are never changed, and when desired
+// objects of different owners have the same name the first one is applied. Both cases and failed API calls are returned
+// as failures, the error is returned only for objects that are not policy objects
+func Sync(ctx context.Context, client versioned.Interface, resources *common.K8sResources, manager string, desired []runtime.Object) ([]Failure, error) {
+	var failures []Failure
+	wanted := map[common.ObjectReference]types.UID{}
+
+	for _, obj := range desired {
+		// desired objects may come from informer caches, that must not be modified
+		obj = obj.DeepCopyObject()
+		common.SetPolicyObjectDefaults(obj)
+
+		ref, err := common.PolicyObjectReference(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		owner := controllerUID(objMeta)
+
+		if wantedOwner, ok := wanted[ref]; ok {
+			if wantedOwner != owner {
+				failures = append(failures, conflict(manager, ref, owner, fmt.Errorf("%s is also generated from another object", ref)))
+			}
+			continue
+		}
+		wanted[ref] = owner
+
+		labels := objMeta.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[common.ManagedByLabel] = manager
+		objMeta.SetLabels(labels)
+
+		current := resources.Object(ref)
+		if current == nil {
+			if err := common.CreatePolicyObject(ctx, client, obj); err != nil {
+				failures = append(failures, Failure{Object: ref, Owner: owner, Err: fmt.Errorf("failed to create %s: %w", ref, err)})
+				continue
+			}
+			log.Infof("%s: created %s", manager, ref)
+			continue
+		}
+
+		currentMeta, err := meta.Accessor(current)
+		if err != nil {
+			return nil, err
+		}
+
+		if currentMeta.GetLabels()[common.ManagedByLabel] != manager {
+			failures = append(failures, conflict(manager, ref, owner, fmt.Errorf("%s already exists and is not managed by %s", ref, manager)))
+			continue
+		}
+
+		if common.PolicyObjectSpecEqual(obj, current) &&
+			containsAll(currentMeta.GetLabels(), objMeta.GetLabels()) &&
+			containsAll(currentMeta.GetAnnotations(), objMeta.GetAnnotations()) &&
+			equality.Semantic.DeepEqual(objMeta.GetOwnerReferences(), currentMeta.GetOwnerReferences()) {
+			continue
+		}
+
+		objMeta.SetLabels(merge(currentMeta.GetLabels(), objMeta.GetLabels()))
+		objMeta.SetAnnotations(merge(currentMeta.GetAnnotations(), objMeta.GetAnnotations()))
+		objMeta.SetFinalizers(currentMeta.GetFinalizers())
+		objMeta.SetResourceVersion(currentMeta.GetResourceVersion())
+		if err := common.UpdatePolicyObject(ctx, client, obj); err != nil {
+			failures = append(failures, Failure{Object: ref, Owner: owner, Err: fmt.Errorf("failed to update %s: %w", ref, err)})
+			continue
+		}
+		log.Infof("%s: updated %s", manager, ref)
+	}
+
+	for _, current := range managedObjects(resources, manager) {
+		ref, err := common.PolicyObjectReference(current)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := wanted[ref]; ok {
+			continue
+		}
+
+		if err := common.DeletePolicyObject(ctx, client, ref, false); err != nil {
+			currentMeta, metaErr := meta.Accessor(current)
+			if metaErr != nil {
+				return nil, metaErr
+			}
+			failures = append(failures, Failure{Object: ref, Owner: controllerUID(currentMeta), Err: fmt.Errorf("failed to delete %s: %w", ref, err)})
+			continue
+		}
+		log.Infof("%s: deleted %s", manager, ref)
+	}
+
+	return failures, nil
+}
+
+// FailuresError aggregates failed API calls, conflicts are expected when users create objects themselves
+// and are not errors of the reconciler
+func FailuresError(failures []Failure) error {
+	var errs []error
+	for _, failure := range failures {
+		if !failure.Conflict {
+			errs = append(errs, failure.Err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func conflict(manager string, ref common.ObjectReference, owner types.UID, err error) Failure {
+	log.Warnf("%s: %s, skipped", manager, err)
+	return Failure{Object: ref, Owner: owner, Conflict: true, Err: err}
+}
+
+func controllerUID(obj metav1.Object) types.UID {
+	if controller := metav1.GetControllerOf(obj); controller != nil {
+		return controller.UID
+	}
+	return ""
+}
+
+// containsAll tells if all entries of desired are in current
+func containsAll(current, desired map[string]string) bool {
+	for key, value := range desired {
+		if actual, ok := current[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// merge returns entries of current overridden by desired ones
+func merge(current, desired map[string]string) map[string]string {
+	if len(current) == 0 && len(desired) == 0 {
+		return nil
+	}
+
+	merged := map[string]string{}
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range desired {
+		merged[key] = value
+	}
+	return merged
+}
+
+func managedObjects(resources *common.K8sResources, manager string) []runtime.Object {
+	var objects []runtime.Object
+
+	for _, obj := range resources.Servers {
+		if obj.Labels[common.ManagedByLabel] == manager {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.AuthorizationPolicies {
+		if obj.Labels[common.ManagedByLabel] == manager {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.MeshTLSAuthentications {
+		if obj.Labels[common.ManagedByLabel] == manager {
+			objects = append(objects, obj)
+		}
+	}
+
+	for _, obj := range resources.NetworkAuthentications {
+		if obj.Labels[common.ManagedByLabel] == manager {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,43 @@
+package servers
+
+import (
+	"context"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"linkerd-easyauth/controller/reconcile"
+	common "linkerd-easyauth/pkg"
+)
+
+// Reconciler creates Servers requested by workload annotations and deletes Servers
+// that it created earlier but that are not requested anymore
+type Reconciler struct {
+	client versioned.Interface
+}
+
+func NewReconciler(client versioned.Interface) *Reconciler {
+	return &Reconciler{client: client}
+}
+
+func (r *Reconciler) Name() string {
+	return common.ManagedByServersController
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, resources *common.K8sResources) error {
+	servers, warnings := common.AnnotatedServers(resources)
+	for _, warning := range warnings {
+		log.Warnf("%s: %s", r.Name(), warning)
+	}
+
+	desired := make([]runtime.Object, 0, len(servers))
+	for _, srv := range servers {
+		desired = append(desired, srv)
+	}
+
+	failures, err := reconcile.Sync(ctx, r.client, resources, r.Name(), desired)
+	if err != nil {
+		return err
+	}
+
+	return reconcile.FailuresError(failures)
+}
@@ -7,8 +7,17 @@ import (
 	"github.com/linkerd/linkerd2/controller/k8s"
 	"github.com/linkerd/linkerd2/controller/webhook"
 	"github.com/linkerd/linkerd2/pkg/flags"
+	pkgk8s "github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/record"
 	"linkerd-easyauth/injector/mutator"
+	"linkerd-easyauth/injector/validator"
+	common "linkerd-easyauth/pkg"
 	"os"
+	"strings"
+	"time"
+
+	admission "k8s.io/api/admission/v1beta1"
 )
 
 const componentName = "linkerd-easyauth-injector"
@@ -20,13 +29,57 @@ func main() {
 	addr := cmd.String("addr", ":8443", "address to serve on")
 	kubeconfig := cmd.String("kubeconfig", "", "path to kubeconfig")
 	enablePprof := cmd.Bool("enable-pprof", false, "Enable pprof endpoints on the admin server")
+	payloadConfigMap := cmd.String("payload-configmap", "",
+		fmt.Sprintf("namespace/name of the ConfigMap with labels and annotations (%s key) to add to marked pods", mutator.PayloadKey))
+	validation := cmd.Bool("validation", false, "validate created Servers, HTTPRoutes and AuthorizationPolicies")
 
 	flags.ConfigureAndParse(cmd, os.Args[1:])
 
+	ctx := context.Background()
+
+	apiResources := []k8s.APIResource{k8s.NS}
+
+	var k8sAPI *pkgk8s.KubernetesAPI
+	if *payloadConfigMap != "" || *validation {
+		var err error
+		k8sAPI, err = pkgk8s.NewAPI(*kubeconfig, "", "", []string{}, 0)
+		if err != nil {
+			log.Fatalf("failed to create Kubernetes API client: %s", err)
+		}
+	}
+
+	var payloadSource *mutator.PayloadSource
+	if *payloadConfigMap != "" {
+		namespace, name, ok := strings.Cut(*payloadConfigMap, "/")
+		if !ok {
+			log.Fatalf("-payload-configmap must be namespace/name")
+		}
+
+		var err error
+		payloadSource, err = mutator.NewPayloadSource(ctx, k8sAPI, namespace, name)
+		if err != nil {
+			log.Fatalf("failed to start payload informer: %s", err)
+		}
+
+		// ReplicaSets to find owners of pods, the payload ConfigMap has its own informer
+		apiResources = append(apiResources, k8s.RS)
+	}
+
+	handler := mutator.Mutate(payloadSource)
+
+	if *validation {
+		source, err := common.NewInformerSource(ctx, k8sAPI, 10*time.Minute)
+		if err != nil {
+			log.Fatalf("failed to start informers: %s", err)
+		}
+
+		handler = dispatch(handler, validator.Validate(source))
+	}
+
 	webhook.Launch(
-		context.Background(),
-		[]k8s.APIResource{k8s.NS},
-		mutator.Mutate(),
+		ctx,
+		apiResources,
+		handler,
 		componentName,
 		*metricsAddr,
 		*addr,
@@ -34,3 +87,26 @@ func main() {
 		*enablePprof,
 	)
 }
+
+// dispatch serves both webhooks on the same address, pods are mutated, policy objects are validated
+// and other kinds are allowed as is
+func dispatch(mutate, validate webhook.Handler) webhook.Handler {
+	return func(
+		ctx context.Context,
+		api *k8s.API,
+		request *admission.AdmissionRequest,
+		recorder record.EventRecorder,
+	) (*admission.AdmissionResponse, error) {
+		switch request.Kind.Kind {
+		case common.PodKind:
+			return mutate(ctx, api, request, recorder)
+		case common.ServerKind, common.HTTPRouteKind, common.AuthorizationPolicyKind:
+			return validate(ctx, api, request, recorder)
+		}
+
+		return &admission.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: true,
+		}, nil
+	}
+}
@@ -1,9 +1,60 @@
 package mutator
 
-const patch = `[
-	{
-		"op": "add",
-		"path": "/metadata/labels/linkerd.io~1easyauth-enabled",
-		"value": "true"
-  	}
-]`
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+)
+
+// PatchOperation is a single JSON patch operation
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+var pathEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// BuildPatch returns operations that set labels and annotations of the pod, the maps are created when the pod has none,
+// keys with other values are replaced and keys that already have desired values are skipped
+func BuildPatch(pod *corev1.Pod, labels, annotations map[string]string) []PatchOperation {
+	var ops []PatchOperation
+	ops = append(ops, mapPatch("/metadata/labels", pod.Labels, labels)...)
+	ops = append(ops, mapPatch("/metadata/annotations", pod.Annotations, annotations)...)
+	return ops
+}
+
+func mapPatch(path string, current, desired map[string]string) []PatchOperation {
+	missing := map[string]string{}
+	for k, v := range desired {
+		if value, ok := current[k]; !ok || value != v {
+			missing[k] = v
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// "add" of a key fails when the parent map doesn't exist, so the whole map is added
+	if current == nil {
+		return []PatchOperation{{Op: "add", Path: path, Value: missing}}
+	}
+
+	keys := make([]string, 0, len(missing))
+	for k := range missing {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ops := make([]PatchOperation, 0, len(keys))
+	for _, k := range keys {
+		op := "add"
+		if _, ok := current[k]; ok {
+			op = "replace"
+		}
+		ops = append(ops, PatchOperation{Op: op, Path: path + "/" + pathEscaper.Replace(k), Value: missing[k]})
+	}
+
+	return ops
+}
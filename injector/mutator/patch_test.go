@@ -0,0 +1,120 @@
+package mutator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	common "linkerd-easyauth/pkg"
+
+	admission "k8s.io/api/admission/v1beta1"
+)
+
+// readRequest returns the request of an AdmissionReview fixture
+func readRequest(t *testing.T, fixture string) *admission.AdmissionRequest {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %s", err)
+	}
+
+	var review admission.AdmissionReview
+	if err := json.Unmarshal(content, &review); err != nil {
+		t.Fatalf("failed to decode AdmissionReview: %s", err)
+	}
+
+	return review.Request
+}
+
+// readPod decodes the pod of an AdmissionReview fixture the same way the webhook does
+func readPod(t *testing.T, fixture string) *corev1.Pod {
+	t.Helper()
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(readRequest(t, fixture).Object.Raw, &pod); err != nil {
+		t.Fatalf("failed to decode pod: %s", err)
+	}
+
+	return &pod
+}
+
+func TestBuildPatch(t *testing.T) {
+	marker := map[string]string{common.EasyAuthEnabledKey: common.EasyAuthEnabledValue}
+
+	testCases := []struct {
+		name        string
+		fixture     string
+		labels      map[string]string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:        "maps are added as a whole when the pod has none",
+			fixture:     "pod-without-labels-and-annotations.json",
+			labels:      marker,
+			annotations: marker,
+			expected: `[
+				{"op": "add", "path": "/metadata/labels", "value": {"linkerd.io/easyauth-enabled": "true"}},
+				{"op": "add", "path": "/metadata/annotations", "value": {"linkerd.io/easyauth-enabled": "true"}}
+			]`,
+		},
+		{
+			name:        "keys with desired values are skipped",
+			fixture:     "pod-marked.json",
+			labels:      marker,
+			annotations: marker,
+			expected:    `null`,
+		},
+		{
+			name:        "keys with other values are replaced and missing keys are added",
+			fixture:     "pod-marker-disabled.json",
+			labels:      marker,
+			annotations: marker,
+			expected: `[
+				{"op": "replace", "path": "/metadata/labels/linkerd.io~1easyauth-enabled", "value": "true"},
+				{"op": "add", "path": "/metadata/annotations/linkerd.io~1easyauth-enabled", "value": "true"}
+			]`,
+		},
+		{
+			name:    "slashes and tildes in keys are escaped",
+			fixture: "pod-with-labels.json",
+			labels: map[string]string{
+				"example.com/team": "checkout",
+				"example.com/a~b":  "value",
+				"app":              "web",
+			},
+			expected: `[
+				{"op": "add", "path": "/metadata/labels/example.com~1a~0b", "value": "value"},
+				{"op": "replace", "path": "/metadata/labels/example.com~1team", "value": "checkout"}
+			]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ops := BuildPatch(readPod(t, tc.fixture), tc.labels, tc.annotations)
+
+			actualJSON, err := json.Marshal(ops)
+			if err != nil {
+				t.Fatalf("failed to marshal patch: %s", err)
+			}
+
+			var actual, expected interface{}
+			if err := json.Unmarshal(actualJSON, &actual); err != nil {
+				t.Fatalf("failed to decode patch: %s", err)
+			}
+			if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+				t.Fatalf("failed to decode expected patch: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("expected patch %s, got %s", tc.expected, actualJSON)
+			}
+		})
+	}
+}
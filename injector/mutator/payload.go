@@ -0,0 +1,207 @@
+package mutator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// PayloadKey is the ConfigMap key that holds the payload
+const PayloadKey = "payload.yaml"
+
+// Payload are labels and annotations that the injector adds to marked pods in addition to the marker,
+// values are text templates rendered with PayloadParams, e.g. "{{ .Namespace }}-{{ .OwnerName }}"
+type Payload struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	labels      map[string]*template.Template
+	annotations map[string]*template.Template
+}
+
+// PayloadParams are pod properties that payload templates can use
+type PayloadParams struct {
+	Namespace      string
+	ServiceAccount string
+	OwnerKind      string
+	OwnerName      string
+}
+
+// samplePayloadParams are used to check label values when the payload is parsed,
+// values that depend on pod properties are checked again by Render
+var samplePayloadParams = PayloadParams{
+	Namespace:      "default",
+	ServiceAccount: "default",
+	OwnerKind:      "Deployment",
+	OwnerName:      "app",
+}
+
+// ParsePayload parses the payload and its templates, keys must be valid label and annotation keys
+// and label values rendered with sample params must be valid label values
+func ParsePayload(content []byte) (*Payload, error) {
+	payload := &Payload{}
+	if err := yaml.UnmarshalStrict(content, payload); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if payload.labels, err = parseTemplates(payload.Labels); err != nil {
+		return nil, err
+	}
+	if payload.annotations, err = parseTemplates(payload.Annotations); err != nil {
+		return nil, err
+	}
+
+	for k := range payload.Annotations {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid annotation key %s: %s", k, strings.Join(errs, "; "))
+		}
+	}
+
+	if _, _, err := payload.Render(samplePayloadParams); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// Render returns labels and annotations with rendered values, labels are validated
+// because the API server rejects pods with invalid ones
+func (p *Payload) Render(params PayloadParams) (map[string]string, map[string]string, error) {
+	labels, err := renderTemplates(p.labels, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("invalid label key %s: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("invalid value %q of label %s: %s", v, k, strings.Join(errs, "; "))
+		}
+	}
+
+	annotations, err := renderTemplates(p.annotations, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return labels, annotations, nil
+}
+
+func parseTemplates(values map[string]string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for k, v := range values {
+		t, err := template.New(k).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for %s: %w", k, err)
+		}
+		templates[k] = t
+	}
+	return templates, nil
+}
+
+func renderTemplates(templates map[string]*template.Template, params PayloadParams) (map[string]string, error) {
+	values := map[string]string{}
+	for k, t := range templates {
+		var value bytes.Buffer
+		if err := t.Execute(&value, params); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", k, err)
+		}
+		values[k] = value.String()
+	}
+	return values, nil
+}
+
+// PayloadSource reads the payload from a ConfigMap through the informer cache, so changes apply without restarts.
+// The payload is parsed once per ConfigMap version, an invalid version is ignored and the last valid payload is used
+type PayloadSource struct {
+	namespace string
+	name      string
+	lister    corelisters.ConfigMapNamespaceLister
+
+	mu              sync.Mutex
+	resourceVersion string
+	payload         *Payload
+}
+
+// NewPayloadSource starts an informer that watches only the payload ConfigMap,
+// so the injector needs access to that ConfigMap only and not to ConfigMaps of all namespaces
+func NewPayloadSource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace, name string) (*PayloadSource, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sAPI.Interface, 10*time.Minute,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	// the informer should be requested before the factory is started
+	configMaps := factory.Core().V1().ConfigMaps()
+	synced := configMaps.Informer().HasSynced
+	factory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced) {
+		return nil, errors.New("failed to sync payload ConfigMap informer")
+	}
+
+	return &PayloadSource{
+		namespace: namespace,
+		name:      name,
+		lister:    configMaps.Lister().ConfigMaps(namespace),
+		payload:   &Payload{},
+	}, nil
+}
+
+// Get returns the current payload, a missing ConfigMap means an empty payload
+func (s *PayloadSource) Get() (*Payload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cm, err := s.lister.Get(s.name)
+	if kerrors.IsNotFound(err) {
+		if s.resourceVersion != "" {
+			log.Infof("payload ConfigMap %s/%s is deleted, no payload is added", s.namespace, s.name)
+		}
+		s.resourceVersion = ""
+		s.payload = &Payload{}
+		return s.payload, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cm.ResourceVersion == s.resourceVersion {
+		return s.payload, nil
+	}
+	s.resourceVersion = cm.ResourceVersion
+
+	payload, err := ParsePayload([]byte(cm.Data[PayloadKey]))
+	if err != nil {
+		log.Errorf("ignoring invalid payload ConfigMap %s/%s version %s: %s", s.namespace, s.name, cm.ResourceVersion, err)
+		return s.payload, nil
+	}
+
+	log.Infof("loaded payload ConfigMap %s/%s version %s", s.namespace, s.name, cm.ResourceVersion)
+	s.payload = payload
+
+	return s.payload, nil
+}
@@ -0,0 +1,105 @@
+package mutator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePayload(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name: "templates",
+			content: `
+labels:
+  team: payments
+  owner: "{{ .OwnerKind }}-{{ .OwnerName }}"
+annotations:
+  example.com/service-account: "{{ .Namespace }}/{{ .ServiceAccount }}"
+`,
+		},
+		{
+			name:    "unknown field",
+			content: "label:\n  team: payments\n",
+			err:     "unknown field",
+		},
+		{
+			name:    "invalid template",
+			content: "labels:\n  team: \"{{ .Team\"\n",
+			err:     "invalid template for team",
+		},
+		{
+			name:    "invalid label value",
+			content: "labels:\n  team: payments/checkout\n",
+			err:     "invalid value",
+		},
+		{
+			name:    "invalid rendered label value",
+			content: "labels:\n  service-account: \"{{ .Namespace }}/{{ .ServiceAccount }}\"\n",
+			err:     "invalid value",
+		},
+		{
+			name:    "invalid label key",
+			content: "labels:\n  a~b: payments\n",
+			err:     "invalid label key",
+		},
+		{
+			name:    "invalid annotation key",
+			content: "annotations:\n  a~b: payments\n",
+			err:     "invalid annotation key",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePayload([]byte(tc.content))
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestPayloadRender(t *testing.T) {
+	payload, err := ParsePayload([]byte(`
+labels:
+  owner: "{{ .OwnerName }}"
+annotations:
+  example.com/service-account: "{{ .Namespace }}/{{ .ServiceAccount }}"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	labels, annotations, err := payload.Render(PayloadParams{
+		Namespace:      "app",
+		ServiceAccount: "web",
+		OwnerKind:      "Deployment",
+		OwnerName:      "web",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]string{"owner": "web"}; !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected labels %v, got %v", expected, labels)
+	}
+	if expected := map[string]string{"example.com/service-account": "app/web"}; !reflect.DeepEqual(annotations, expected) {
+		t.Fatalf("expected annotations %v, got %v", expected, annotations)
+	}
+
+	// values that depend on pod properties can still be invalid for some pods
+	if _, _, err := payload.Render(PayloadParams{OwnerName: strings.Repeat("a", 64)}); err == nil {
+		t.Fatal("expected an error for a label value longer than 63 characters")
+	}
+}
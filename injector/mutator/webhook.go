@@ -1,20 +1,22 @@
 package mutator
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/linkerd/linkerd2/controller/k8s"
 	"github.com/linkerd/linkerd2/controller/webhook"
-	"html/template"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/tools/record"
+	common "linkerd-easyauth/pkg"
 
 	admission "k8s.io/api/admission/v1beta1"
 )
 
-type Params struct {
-}
-
-func Mutate() webhook.Handler {
+// Mutate marks pods that are injected by Linkerd, the payload is added too when the source is not nil
+func Mutate(payloadSource *PayloadSource) webhook.Handler {
 	return func(
 		ctx context.Context,
 		api *k8s.API,
@@ -26,22 +28,109 @@ func Mutate() webhook.Handler {
 			Allowed: true,
 		}
 
-		params := Params{}
+		var pod corev1.Pod
+		if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+			return nil, fmt.Errorf("failed to decode pod: %w", err)
+		}
 
-		t, err := template.New("patch").Parse(patch)
-		if err != nil {
+		namespace, err := api.NS().Lister().Get(request.Namespace)
+		if err != nil && !kerrors.IsNotFound(err) {
 			return nil, err
 		}
 
-		var patchJSON bytes.Buffer
-		if err = t.Execute(&patchJSON, params); err != nil {
+		if !common.IsInjectedByLinkerd(&pod, namespace) {
+			log.Debugf("skipping pod %s/%s: it is not injected by Linkerd", request.Namespace, podName(&pod))
+			return admissionResponse, nil
+		}
+
+		if common.IsEasyAuthInjectDisabled(&pod, namespace) {
+			log.Debugf("skipping pod %s/%s: %s is %s", request.Namespace, podName(&pod), common.EasyAuthInjectAnnotation, common.EasyAuthInjectDisabled)
+			return admissionResponse, nil
+		}
+
+		labels, annotations := map[string]string{}, map[string]string{}
+		if payloadSource != nil {
+			labels, annotations = renderPayload(ctx, api, payloadSource, &pod, request.Namespace)
+			// the payload only adds keys, values that the pod has are kept
+			deleteExisting(labels, pod.Labels)
+			deleteExisting(annotations, pod.Annotations)
+		}
+
+		// the namespace default policy is set unless the pod has its own one
+		defaultPolicy, err := common.NamespaceDefaultPolicy(namespace)
+		if err != nil {
+			log.Warnf("not setting %s on pod %s/%s: %s", common.DefaultInboundPolicyAnnotation, request.Namespace, podName(&pod), err)
+		} else if defaultPolicy != "" && pod.Annotations[common.DefaultInboundPolicyAnnotation] == "" {
+			annotations[common.DefaultInboundPolicyAnnotation] = defaultPolicy
+		}
+
+		// the marker can't be overridden by the payload
+		labels[common.EasyAuthEnabledKey] = common.EasyAuthEnabledValue
+		annotations[common.EasyAuthEnabledKey] = common.EasyAuthEnabledValue
+
+		ops := BuildPatch(&pod, labels, annotations)
+		if len(ops) == 0 {
+			return admissionResponse, nil
+		}
+
+		patchJSON, err := json.Marshal(ops)
+		if err != nil {
 			return nil, err
 		}
 
 		patchType := admission.PatchTypeJSONPatch
-		admissionResponse.Patch = patchJSON.Bytes()
+		admissionResponse.Patch = patchJSON
 		admissionResponse.PatchType = &patchType
 
 		return admissionResponse, nil
 	}
 }
+
+// podName returns generateName for pods of workloads, their names are not known at admission time
+func podName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
+
+func deleteExisting(payload, current map[string]string) {
+	for k := range payload {
+		if _, ok := current[k]; ok {
+			delete(payload, k)
+		}
+	}
+}
+
+// renderPayload returns empty maps if the payload can't be rendered, so pods are still marked
+func renderPayload(ctx context.Context, api *k8s.API, payloadSource *PayloadSource, pod *corev1.Pod, namespace string) (map[string]string, map[string]string) {
+	empty := func() (map[string]string, map[string]string) {
+		return map[string]string{}, map[string]string{}
+	}
+
+	payload, err := payloadSource.Get()
+	if err != nil {
+		log.Errorf("failed to get payload: %s", err)
+		return empty()
+	}
+
+	params := PayloadParams{
+		Namespace:      namespace,
+		ServiceAccount: pod.Spec.ServiceAccountName,
+	}
+	if params.ServiceAccount == "" {
+		params.ServiceAccount = "default"
+	}
+
+	// owners are looked up in the pod namespace that is empty for pods of workloads
+	pod.Namespace = namespace
+	params.OwnerKind, params.OwnerName = api.GetOwnerKindAndName(ctx, pod, true)
+
+	labels, annotations, err := payload.Render(params)
+	if err != nil {
+		log.Errorf("failed to render payload for pod %s/%s: %s", namespace, podName(pod), err)
+		return empty()
+	}
+
+	return labels, annotations
+}
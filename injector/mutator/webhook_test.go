@@ -0,0 +1,224 @@
+package mutator
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/linkerd/linkerd2/controller/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	common "linkerd-easyauth/pkg"
+)
+
+const appNamespace = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app`
+
+// applyPatch applies add and replace operations of the patch to the raw pod, parent objects must exist
+// and replaced members must be present as the API server requires
+func applyPatch(t *testing.T, raw []byte, patch []byte) *corev1.Pod {
+	t.Helper()
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		t.Fatalf("failed to decode pod: %s", err)
+	}
+
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		t.Fatalf("failed to decode patch: %s", err)
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for _, op := range ops {
+		tokens := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+
+		parent := obj
+		for _, token := range tokens[:len(tokens)-1] {
+			child, ok := parent[unescape.Replace(token)].(map[string]interface{})
+			if !ok {
+				t.Fatalf("%s %s: parent %s doesn't exist", op.Op, op.Path, token)
+			}
+			parent = child
+		}
+
+		key := unescape.Replace(tokens[len(tokens)-1])
+		switch _, exists := parent[key]; {
+		case op.Op == "replace" && !exists:
+			t.Fatalf("replace %s: member doesn't exist", op.Path)
+		case op.Op != "add" && op.Op != "replace":
+			t.Fatalf("unexpected operation %s", op.Op)
+		}
+		parent[key] = op.Value
+	}
+
+	patched, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("failed to encode pod: %s", err)
+	}
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(patched, &pod); err != nil {
+		t.Fatalf("failed to decode patched pod: %s", err)
+	}
+
+	return &pod
+}
+
+// testPayloadSource serves the payload from a lister without an informer
+func testPayloadSource(t *testing.T, content string) *PayloadSource {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	err := indexer.Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "linkerd-easyauth", Name: "easyauth-injector-payload", ResourceVersion: "1"},
+		Data:       map[string]string{PayloadKey: content},
+	})
+	if err != nil {
+		t.Fatalf("failed to add ConfigMap: %s", err)
+	}
+
+	return &PayloadSource{
+		namespace: "linkerd-easyauth",
+		name:      "easyauth-injector-payload",
+		lister:    corelisters.NewConfigMapLister(indexer).ConfigMaps("linkerd-easyauth"),
+		payload:   &Payload{},
+	}
+}
+
+func TestMutate(t *testing.T) {
+	marker := common.EasyAuthEnabledKey
+
+	testCases := []struct {
+		name                string
+		fixture             string
+		namespace           string
+		payload             string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:      "pods that are not injected by Linkerd are not patched",
+			fixture:   "pod-without-labels-and-annotations.json",
+			namespace: appNamespace,
+		},
+		{
+			name:    "pods without labels are marked when the namespace is injected",
+			fixture: "pod-without-labels-and-annotations.json",
+			namespace: appNamespace + `
+  annotations:
+    linkerd.io/inject: enabled`,
+			expectedLabels:      map[string]string{marker: "true"},
+			expectedAnnotations: map[string]string{marker: "true"},
+		},
+		{
+			name:                "pods are marked when the namespace doesn't exist in the cache",
+			fixture:             "pod-with-labels.json",
+			expectedLabels:      map[string]string{"app": "web", "example.com/team": "payments", marker: "true"},
+			expectedAnnotations: map[string]string{"linkerd.io/inject": "enabled", marker: "true"},
+		},
+		{
+			name:                "pods that opt out are not patched",
+			fixture:             "pod-opt-out.json",
+			namespace:           appNamespace,
+			expectedLabels:      map[string]string{"app": "web"},
+			expectedAnnotations: map[string]string{"linkerd.io/inject": "enabled", common.EasyAuthInjectAnnotation: common.EasyAuthInjectDisabled},
+		},
+		{
+			name:    "pods of namespaces that opt out are not patched",
+			fixture: "pod-with-labels.json",
+			namespace: appNamespace + `
+  annotations:
+    easyauth.linkerd.io/inject: disabled`,
+			expectedLabels:      map[string]string{"app": "web", "example.com/team": "payments"},
+			expectedAnnotations: map[string]string{"linkerd.io/inject": "enabled"},
+		},
+		{
+			name:    "the namespace default policy is set",
+			fixture: "pod-with-labels.json",
+			namespace: appNamespace + `
+  annotations:
+    easyauth.linkerd.io/default-policy: audit`,
+			expectedLabels: map[string]string{"app": "web", "example.com/team": "payments", marker: "true"},
+			expectedAnnotations: map[string]string{
+				"linkerd.io/inject":                   "enabled",
+				common.DefaultInboundPolicyAnnotation: "audit",
+				marker:                                "true",
+			},
+		},
+		{
+			name:      "the payload adds only keys that the pod doesn't have",
+			fixture:   "pod-with-labels.json",
+			namespace: appNamespace,
+			payload: `
+labels:
+  example.com/team: "{{ .Namespace }}"
+  example.com/namespace: "{{ .Namespace }}"
+annotations:
+  linkerd.io/inject: disabled
+  example.com/service-account: "{{ .ServiceAccount }}"`,
+			expectedLabels: map[string]string{"app": "web", "example.com/team": "payments", "example.com/namespace": "app", marker: "true"},
+			expectedAnnotations: map[string]string{
+				"linkerd.io/inject":           "enabled",
+				"example.com/service-account": "web",
+				marker:                        "true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var configs []string
+			if tc.namespace != "" {
+				configs = append(configs, tc.namespace)
+			}
+
+			api, err := k8s.NewFakeAPI(configs...)
+			if err != nil {
+				t.Fatalf("failed to create API: %s", err)
+			}
+			api.Sync(nil)
+
+			var payloadSource *PayloadSource
+			if tc.payload != "" {
+				payloadSource = testPayloadSource(t, tc.payload)
+			}
+
+			request := readRequest(t, tc.fixture)
+			response, err := Mutate(payloadSource)(context.Background(), api, request, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !response.Allowed || response.UID != request.UID {
+				t.Fatalf("expected the pod to be allowed, got %+v", response)
+			}
+
+			if tc.expectedLabels == nil && tc.expectedAnnotations == nil {
+				if response.Patch != nil {
+					t.Fatalf("expected no patch, got %s", response.Patch)
+				}
+				return
+			}
+
+			pod := readPod(t, tc.fixture)
+			if response.Patch != nil {
+				pod = applyPatch(t, request.Object.Raw, response.Patch)
+			}
+
+			if !reflect.DeepEqual(pod.Labels, tc.expectedLabels) {
+				t.Errorf("expected labels %v, got %v", tc.expectedLabels, pod.Labels)
+			}
+			if !reflect.DeepEqual(pod.Annotations, tc.expectedAnnotations) {
+				t.Errorf("expected annotations %v, got %v", tc.expectedAnnotations, pod.Annotations)
+			}
+		})
+	}
+}
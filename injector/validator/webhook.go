@@ -0,0 +1,88 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	"github.com/linkerd/linkerd2/controller/k8s"
+	"github.com/linkerd/linkerd2/controller/webhook"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	common "linkerd-easyauth/pkg"
+	"strings"
+
+	admission "k8s.io/api/admission/v1beta1"
+)
+
+// Validate checks created policy objects against resources of their namespace, findings are returned as admission warnings.
+// Objects are never rejected: Helm and GitOps tools apply policies before the Servers and authentications they refer to
+func Validate(source *common.InformerSource) webhook.Handler {
+	return func(
+		ctx context.Context,
+		api *k8s.API,
+		request *admission.AdmissionRequest,
+		recorder record.EventRecorder,
+	) (*admission.AdmissionResponse, error) {
+		admissionResponse := &admission.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: true,
+		}
+
+		obj, err := decode(request)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			return admissionResponse, nil
+		}
+
+		resources, err := source.Fetch(ctx, request.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		findings, err := common.ValidatePolicyObject(resources, obj)
+		if err != nil {
+			return nil, err
+		}
+		if len(findings) == 0 {
+			return admissionResponse, nil
+		}
+
+		var warnings []string
+		for _, finding := range findings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", finding.RuleID, finding.Message))
+		}
+
+		log.Debugf("%s %s/%s: %s", request.Kind.Kind, request.Namespace, request.Name, strings.Join(warnings, "; "))
+
+		admissionResponse.Warnings = warnings
+
+		return admissionResponse, nil
+	}
+}
+
+// decode returns nil for kinds that are not validated
+func decode(request *admission.AdmissionRequest) (runtime.Object, error) {
+	var obj runtime.Object
+
+	switch request.Kind.Kind {
+	case common.AuthorizationPolicyKind:
+		obj = &policy.AuthorizationPolicy{}
+	case common.HTTPRouteKind:
+		obj = &policy.HTTPRoute{}
+	case common.ServerKind:
+		obj = &server.Server{}
+	default:
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(request.Object.Raw, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", request.Kind.Kind, err)
+	}
+
+	return obj, nil
+}
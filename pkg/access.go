@@ -0,0 +1,399 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"strconv"
+	"strings"
+)
+
+const (
+	NamespaceKind      = "Namespace"
+	ServiceAccountKind = "ServiceAccount"
+)
+
+// Clients are the clients admitted by a single policy, mesh TLS clients are described by identities,
+// service accounts and namespaces; if both mesh TLS clients and networks are set then both are required.
+// AllOf are clients of further authentications of the policy, a client must be admitted by each of them too
+type Clients struct {
+	Identities      []string  `json:"identities,omitempty"`
+	ServiceAccounts []string  `json:"serviceAccounts,omitempty"`
+	Namespaces      []string  `json:"namespaces,omitempty"`
+	Networks        []Network `json:"networks,omitempty"`
+	Unauthenticated bool      `json:"unauthenticated,omitempty"`
+	AllOf           []Clients `json:"allOf,omitempty"`
+}
+
+// Network is a CIDR with optional exceptions
+type Network struct {
+	Cidr   string   `json:"cidr"`
+	Except []string `json:"except,omitempty"`
+}
+
+// AccessRule is a policy (AuthorizationPolicy or ServerAuthorization) that admits clients to a Server
+type AccessRule struct {
+	Server                 *server.Server
+	Route                  *policy.HTTPRoute
+	Policy                 ObjectReference
+	Authentications        []ObjectReference
+	MissingAuthentications []ObjectReference
+	Clients                Clients
+}
+
+// RequiresMeshTLS is true if clients should have mesh TLS identity
+func (c Clients) RequiresMeshTLS() bool {
+	for _, clients := range c.Groups() {
+		if clients.hasMeshTLS() {
+			return true
+		}
+	}
+	return false
+}
+
+// Groups returns clients of each required authentication, the first group is the clients themselves without AllOf
+func (c Clients) Groups() []Clients {
+	own := c
+	own.AllOf = nil
+	return append([]Clients{own}, c.AllOf...)
+}
+
+func (c Clients) hasMeshTLS() bool {
+	return len(c.Identities) > 0 || len(c.ServiceAccounts) > 0 || len(c.Namespaces) > 0
+}
+
+// require adds clients of one more authentication, they are merged unless clients of the same kind
+// (mesh TLS or networks) are already required, as both have to admit the client
+func (c *Clients) require(authn Clients) {
+	if (authn.hasMeshTLS() && c.hasMeshTLS()) || (len(authn.Networks) > 0 && len(c.Networks) > 0) {
+		c.AllOf = append(c.AllOf, authn)
+		return
+	}
+
+	c.Identities = append(c.Identities, authn.Identities...)
+	c.ServiceAccounts = append(c.ServiceAccounts, authn.ServiceAccounts...)
+	c.Namespaces = append(c.Namespaces, authn.Namespaces...)
+	c.Networks = append(c.Networks, authn.Networks...)
+}
+
+// ServersForPods returns Servers that select any of the pods and one of their container ports,
+// port filters Servers by port name or number (either Server or container one)
+func ServersForPods(resources *K8sResources, pods []v1.Pod, port string) ([]*server.Server, error) {
+	var servers []*server.Server
+
+	for _, srv := range resources.Servers {
+		if srv.Spec.PodSelector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(srv.Spec.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create selector: %w", err)
+		}
+
+		for _, pod := range pods {
+			if pod.Namespace != srv.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+
+			containerPort, ok := ServerContainerPort(srv, pod)
+			if !ok {
+				continue
+			}
+
+			if port == "" || port == srv.Spec.Port.String() || port == containerPort.Name || port == strconv.Itoa(int(containerPort.ContainerPort)) {
+				servers = append(servers, srv)
+				break
+			}
+		}
+	}
+
+	return servers, nil
+}
+
+// ServerContainerPort returns the pod container port that the Server applies to
+func ServerContainerPort(srv *server.Server, pod v1.Pod) (v1.ContainerPort, bool) {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if srv.Spec.Port.IntValue() > 0 {
+				if int(p.ContainerPort) == srv.Spec.Port.IntValue() {
+					return p, true
+				}
+			} else if p.Name == srv.Spec.Port.String() {
+				return p, true
+			}
+		}
+	}
+	return v1.ContainerPort{}, false
+}
+
+// AccessRulesForServer returns all policies that admit clients to the Server, including namespace-wide
+// AuthorizationPolicies and policies for HTTPRoutes attached to the Server
+func AccessRulesForServer(resources *K8sResources, srv *server.Server) ([]AccessRule, error) {
+	candidates, err := authorizationCandidates(resources, []*server.Server{srv})
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []AccessRule
+	for _, candidate := range candidates {
+		rules = append(rules, candidate.rule(resources))
+	}
+
+	return rules, nil
+}
+
+// ServerAuthorizationAppliesToServer is true if the ServerAuthorization selects the Server by name or labels
+func ServerAuthorizationAppliesToServer(serverAuthorization *saz.ServerAuthorization, srv *server.Server) (bool, error) {
+	if serverAuthorization.Namespace != srv.Namespace {
+		return false, nil
+	}
+
+	if serverAuthorization.Spec.Server.Name != "" {
+		return serverAuthorization.Spec.Server.Name == srv.Name, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(serverAuthorization.Spec.Server.Selector)
+	if err != nil {
+		return false, fmt.Errorf("failed to create selector: %w", err)
+	}
+
+	return selector.Matches(labels.Set(srv.Labels)), nil
+}
+
+// PolicyTargetsServer is true if the AuthorizationPolicy targets the Server directly or its namespace
+func PolicyTargetsServer(authzPolicy *policy.AuthorizationPolicy, srv *server.Server) bool {
+	if authzPolicy.Namespace != srv.Namespace {
+		return false
+	}
+
+	target := authzPolicy.Spec.TargetRef
+	switch {
+	case target.Kind == NamespaceKind:
+		return true
+	case target.Kind == k8s.ServerKind && target.Group == k8s.PolicyAPIGroup:
+		return string(target.Name) == srv.Name
+	}
+	return false
+}
+
+// PolicyRoutes returns HTTPRoutes targeted by the AuthorizationPolicy
+func PolicyRoutes(resources *K8sResources, authzPolicy *policy.AuthorizationPolicy) []*policy.HTTPRoute {
+	var routes []*policy.HTTPRoute
+
+	if authzPolicy.Spec.TargetRef.Kind != k8s.HTTPRouteKind {
+		return routes
+	}
+
+	for _, route := range resources.HTTPRoutes {
+		if route.Namespace == authzPolicy.Namespace && route.Name == string(authzPolicy.Spec.TargetRef.Name) {
+			routes = append(routes, route)
+		}
+	}
+
+	return routes
+}
+
+// RouteAttachedToServer is true if one of HTTPRoute parents is the Server
+func RouteAttachedToServer(route *policy.HTTPRoute, srv *server.Server) bool {
+	for _, parentRef := range route.Spec.ParentRefs {
+		if parentRef.Kind == nil || *parentRef.Kind != k8s.ServerKind {
+			continue
+		}
+
+		namespace := route.Namespace
+		if parentRef.Namespace != nil {
+			namespace = string(*parentRef.Namespace)
+		}
+
+		if namespace == srv.Namespace && string(parentRef.Name) == srv.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func serverAuthorizationRule(serverAuthorization *saz.ServerAuthorization, srv *server.Server) AccessRule {
+	rule := AccessRule{
+		Server: srv,
+		Policy: ObjectReference{Kind: ServerAuthorizationKind, Namespace: serverAuthorization.Namespace, Name: serverAuthorization.Name},
+	}
+
+	client := serverAuthorization.Spec.Client
+	for _, network := range client.Networks {
+		rule.Clients.Networks = append(rule.Clients.Networks, Network{Cidr: network.Cidr, Except: network.Except})
+	}
+
+	if client.Unauthenticated {
+		rule.Clients.Unauthenticated = true
+	} else if client.MeshTLS != nil {
+		// TLS connections without client identity are unauthenticated in terms of mesh identity
+		rule.Clients.Unauthenticated = client.MeshTLS.UnauthenticatedTLS
+
+		rule.Clients.Identities = append(rule.Clients.Identities, client.MeshTLS.Identities...)
+
+		for _, sa := range client.MeshTLS.ServiceAccounts {
+			namespace := sa.Namespace
+			if namespace == "" {
+				namespace = serverAuthorization.Namespace
+			}
+			rule.Clients.ServiceAccounts = append(rule.Clients.ServiceAccounts, namespace+"/"+sa.Name)
+		}
+	}
+
+	return rule
+}
+
+func authorizationPolicyRule(resources *K8sResources, authzPolicy *policy.AuthorizationPolicy, srv *server.Server, route *policy.HTTPRoute) AccessRule {
+	rule := AccessRule{
+		Server: srv,
+		Route:  route,
+		Policy: ObjectReference{Kind: AuthorizationPolicyKind, Namespace: authzPolicy.Namespace, Name: authzPolicy.Name},
+	}
+
+	if len(authzPolicy.Spec.RequiredAuthenticationRefs) == 0 {
+		rule.Clients.Unauthenticated = true
+		return rule
+	}
+
+	// Linkerd requires all authentications of the policy, so each of them is evaluated on its own
+	for _, ref := range authzPolicy.Spec.RequiredAuthenticationRefs {
+		namespace := authzPolicy.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+		authnRef := ObjectReference{Kind: string(ref.Kind), Namespace: namespace, Name: string(ref.Name)}
+
+		var authnClients Clients
+
+		switch string(ref.Kind) {
+		case MeshTLSAuthenticationKind:
+			authn := findMeshTLSAuthentication(resources, namespace, string(ref.Name))
+			if authn == nil {
+				rule.MissingAuthentications = append(rule.MissingAuthentications, authnRef)
+				continue
+			}
+			authnClients.Identities = append(authnClients.Identities, authn.Spec.Identities...)
+
+			for _, identityRef := range authn.Spec.IdentityRefs {
+				identityNamespace := authn.Namespace
+				if identityRef.Namespace != nil {
+					identityNamespace = string(*identityRef.Namespace)
+				}
+
+				switch string(identityRef.Kind) {
+				case ServiceAccountKind:
+					authnClients.ServiceAccounts = append(authnClients.ServiceAccounts, identityNamespace+"/"+string(identityRef.Name))
+				case NamespaceKind:
+					authnClients.Namespaces = append(authnClients.Namespaces, string(identityRef.Name))
+				}
+			}
+
+		case NetworkAuthenticationKind:
+			authn := findNetworkAuthentication(resources, namespace, string(ref.Name))
+			if authn == nil {
+				rule.MissingAuthentications = append(rule.MissingAuthentications, authnRef)
+				continue
+			}
+
+			for _, network := range authn.Spec.Networks {
+				authnClients.Networks = append(authnClients.Networks, Network{Cidr: network.Cidr, Except: network.Except})
+			}
+
+		case ServiceAccountKind:
+			authnClients.ServiceAccounts = append(authnClients.ServiceAccounts, namespace+"/"+string(ref.Name))
+
+		default:
+			continue
+		}
+
+		rule.Authentications = append(rule.Authentications, authnRef)
+		rule.Clients.require(authnClients)
+	}
+
+	return rule
+}
+
+// PolicyRequiresAuthentication is true if one of the policy requiredAuthenticationRefs resolves to the authentication,
+// refs without namespace point to the namespace of the policy
+func PolicyRequiresAuthentication(authzPolicy *policy.AuthorizationPolicy, authn ObjectReference) bool {
+	for _, ref := range authzPolicy.Spec.RequiredAuthenticationRefs {
+		namespace := authzPolicy.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+
+		if string(ref.Kind) == authn.Kind && namespace == authn.Namespace && string(ref.Name) == authn.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func findMeshTLSAuthentication(resources *K8sResources, namespace, name string) *policy.MeshTLSAuthentication {
+	for _, authn := range resources.MeshTLSAuthentications {
+		if authn.Namespace == namespace && authn.Name == name {
+			return authn
+		}
+	}
+	return nil
+}
+
+func findNetworkAuthentication(resources *K8sResources, namespace, name string) *policy.NetworkAuthentication {
+	for _, authn := range resources.NetworkAuthentications {
+		if authn.Namespace == namespace && authn.Name == name {
+			return authn
+		}
+	}
+	return nil
+}
+
+func (n Network) String() string {
+	if len(n.Except) == 0 {
+		return n.Cidr
+	}
+	return fmt.Sprintf("%s (except %s)", n.Cidr, strings.Join(n.Except, ", "))
+}
+
+// OutboundRule is an access rule that admits a client to a Server
+type OutboundRule struct {
+	AccessRule
+	AdmittedBy string
+}
+
+// OutboundRulesForClient returns rules of all Servers that admit the client, rules that admit
+// unauthenticated clients are returned only if includeUnauthenticated is set
+func OutboundRulesForClient(resources *K8sResources, client Client, includeUnauthenticated bool) ([]OutboundRule, error) {
+	var outbound []OutboundRule
+
+	for _, srv := range resources.Servers {
+		rules, err := AccessRulesForServer(resources, srv)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			if len(rule.MissingAuthentications) > 0 {
+				continue
+			}
+
+			if !rule.Clients.RequiresMeshTLS() && !includeUnauthenticated {
+				continue
+			}
+
+			admittedBy, ok := rule.Clients.Admits(client)
+			if !ok {
+				continue
+			}
+
+			outbound = append(outbound, OutboundRule{AccessRule: rule, AdmittedBy: admittedBy})
+		}
+	}
+
+	return outbound, nil
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"testing"
+)
+
+func testAuthenticationRefs(authzPolicy *policy.AuthorizationPolicy, kind string, names ...string) *policy.AuthorizationPolicy {
+	for _, name := range names {
+		authzPolicy.Spec.RequiredAuthenticationRefs = append(authzPolicy.Spec.RequiredAuthenticationRefs, gatewayapiv1alpha2.PolicyTargetReference{
+			Group: k8s.PolicyAPIGroup,
+			Kind:  gatewayapiv1alpha2.Kind(kind),
+			Name:  gatewayapiv1alpha2.ObjectName(name),
+		})
+	}
+	return authzPolicy
+}
+
+func TestAccessRulesRequireAllAuthentications(t *testing.T) {
+	resources := testResources()
+	srv := testServer("app", "web", "web", 8080)
+	resources.Servers = append(resources.Servers, srv)
+	resources.MeshTLSAuthentications = append(resources.MeshTLSAuthentications,
+		testMeshTLSAuthentication("app", "clients",
+			ServiceAccountIdentity("client", "app", "linkerd", DefaultTrustDomain),
+			ServiceAccountIdentity("gateway", "ingress", "linkerd", DefaultTrustDomain),
+		),
+		testMeshTLSAuthentication("app", "same-namespace", "*.app.serviceaccount.identity.linkerd.cluster.local"),
+	)
+	resources.NetworkAuthentications = append(resources.NetworkAuthentications,
+		testNetworkAuthentication("app", "cluster", "10.0.0.0/8"),
+		testNetworkAuthentication("app", "nodes", "10.1.0.0/16"),
+	)
+
+	meshPolicy := testAuthenticationRefs(testPolicy("app", "mesh", ServerKind, "web"), MeshTLSAuthenticationKind, "clients", "same-namespace")
+	networkPolicy := testAuthenticationRefs(testPolicy("app", "network", ServerKind, "web"), NetworkAuthenticationKind, "cluster", "nodes")
+	resources.AuthorizationPolicies = append(resources.AuthorizationPolicies, meshPolicy, networkPolicy)
+
+	rules, err := AccessRulesForServer(resources, srv)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+
+	testCases := []struct {
+		name       string
+		rule       AccessRule
+		client     Client
+		admitted   bool
+		admittedBy string
+		network    string
+	}{
+		{
+			name:       "client matches both mesh TLS authentications",
+			rule:       rules[0],
+			client:     testClient("app", "client"),
+			admitted:   true,
+			admittedBy: "identity client.app.serviceaccount.identity.linkerd.cluster.local and identity *.app.serviceaccount.identity.linkerd.cluster.local",
+		},
+		{
+			name:   "client matches one mesh TLS authentication only",
+			rule:   rules[0],
+			client: testClient("ingress", "gateway"),
+		},
+		{
+			name:   "client matches the other mesh TLS authentication only",
+			rule:   rules[0],
+			client: testClient("app", "other"),
+		},
+		{
+			name:       "client IP is in both networks",
+			rule:       rules[1],
+			client:     testClient("app", "client", "10.1.0.1"),
+			admitted:   true,
+			admittedBy: "unauthenticated",
+			network:    "network 10.0.0.0/8 and network 10.1.0.0/16",
+		},
+		{
+			name:   "client IP is in one network only",
+			rule:   rules[1],
+			client: testClient("app", "client", "10.2.0.1"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			admittedBy, admittedByIdentity := tc.rule.Clients.Admits(tc.client)
+			network, admittedByNetwork := tc.rule.Clients.AdmitsNetwork(tc.client)
+
+			if admitted := admittedByIdentity && admittedByNetwork; admitted != tc.admitted {
+				t.Fatalf("expected admitted %t, got %t (%q, %q)", tc.admitted, admitted, admittedBy, network)
+			}
+			if tc.admitted && (admittedBy != tc.admittedBy || network != tc.network) {
+				t.Fatalf("expected to be admitted by %q from %q, got %q from %q", tc.admittedBy, tc.network, admittedBy, network)
+			}
+		})
+	}
+}
+
+func TestOutboundRulesForClient(t *testing.T) {
+	resources := testResources()
+	resources.Servers = append(resources.Servers,
+		testServer("app", "web", "web", 8080),
+		testServer("monitoring", "metrics", "prometheus", 9090),
+		testServer("public", "site", "site", 80),
+	)
+	resources.MeshTLSAuthentications = append(resources.MeshTLSAuthentications,
+		testMeshTLSAuthentication("app", "clients", "*.app.serviceaccount.identity.linkerd.cluster.local"),
+	)
+	resources.AuthorizationPolicies = append(resources.AuthorizationPolicies,
+		testAuthenticationRefs(testPolicy("app", "web", ServerKind, "web"), MeshTLSAuthenticationKind, "clients"),
+		testPolicy("public", "site", ServerKind, "site"),
+	)
+	resources.ServerAuthorizations = append(resources.ServerAuthorizations,
+		testServerAuthorization("monitoring", "metrics", "metrics", saz.Client{
+			MeshTLS: &saz.MeshTLS{ServiceAccounts: []*saz.ServiceAccountName{{Namespace: "app", Name: "client"}}},
+		}),
+	)
+
+	describe := func(rules []OutboundRule) []string {
+		lines := []string{}
+		for _, rule := range rules {
+			lines = append(lines, rule.Server.Namespace+"/"+rule.Server.Name+" "+rule.Policy.String()+" "+rule.AdmittedBy)
+		}
+		return lines
+	}
+
+	rules, err := OutboundRulesForClient(resources, testClient("app", "client"), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertStrings(t, "rules", []string{
+		"app/web app/AuthorizationPolicy/web identity *.app.serviceaccount.identity.linkerd.cluster.local",
+		"monitoring/metrics monitoring/ServerAuthorization/metrics serviceaccount app/client",
+	}, describe(rules))
+
+	rules, err = OutboundRulesForClient(resources, testClient("other", "client"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertStrings(t, "rules", []string{"public/site public/AuthorizationPolicy/site unauthenticated"}, describe(rules))
+}
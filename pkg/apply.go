@@ -0,0 +1,96 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PolicyObjectReference returns the reference to a Server, AuthorizationPolicy or authentication,
+// objects from listers have no TypeMeta so the kind is taken from the type
+func PolicyObjectReference(obj runtime.Object) (ObjectReference, error) {
+	switch o := obj.(type) {
+	case *server.Server:
+		return ObjectReference{Kind: ServerKind, Namespace: o.Namespace, Name: o.Name}, nil
+	case *policy.AuthorizationPolicy:
+		return ObjectReference{Kind: AuthorizationPolicyKind, Namespace: o.Namespace, Name: o.Name}, nil
+	case *policy.MeshTLSAuthentication:
+		return ObjectReference{Kind: MeshTLSAuthenticationKind, Namespace: o.Namespace, Name: o.Name}, nil
+	case *policy.NetworkAuthentication:
+		return ObjectReference{Kind: NetworkAuthenticationKind, Namespace: o.Namespace, Name: o.Name}, nil
+	}
+
+	return ObjectReference{}, fmt.Errorf("unsupported object %T", obj)
+}
+
+// CreatePolicyObject creates a Server, AuthorizationPolicy or authentication
+func CreatePolicyObject(ctx context.Context, client versioned.Interface, obj runtime.Object) error {
+	var err error
+
+	switch o := obj.(type) {
+	case *server.Server:
+		_, err = client.ServerV1beta1().Servers(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *policy.AuthorizationPolicy:
+		_, err = client.PolicyV1alpha1().AuthorizationPolicies(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *policy.MeshTLSAuthentication:
+		_, err = client.PolicyV1alpha1().MeshTLSAuthentications(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+	case *policy.NetworkAuthentication:
+		_, err = client.PolicyV1alpha1().NetworkAuthentications(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+	default:
+		err = fmt.Errorf("unsupported object %T", obj)
+	}
+
+	return err
+}
+
+// UpdatePolicyObject replaces a Server, AuthorizationPolicy or authentication, the object should have the resource version of the current one
+func UpdatePolicyObject(ctx context.Context, client versioned.Interface, obj runtime.Object) error {
+	var err error
+
+	switch o := obj.(type) {
+	case *server.Server:
+		_, err = client.ServerV1beta1().Servers(o.Namespace).Update(ctx, o, metav1.UpdateOptions{})
+	case *policy.AuthorizationPolicy:
+		_, err = client.PolicyV1alpha1().AuthorizationPolicies(o.Namespace).Update(ctx, o, metav1.UpdateOptions{})
+	case *policy.MeshTLSAuthentication:
+		_, err = client.PolicyV1alpha1().MeshTLSAuthentications(o.Namespace).Update(ctx, o, metav1.UpdateOptions{})
+	case *policy.NetworkAuthentication:
+		_, err = client.PolicyV1alpha1().NetworkAuthentications(o.Namespace).Update(ctx, o, metav1.UpdateOptions{})
+	default:
+		err = fmt.Errorf("unsupported object %T", obj)
+	}
+
+	return err
+}
+
+// SetPolicyObjectDefaults sets fields that the CRDs default, so generated objects compare equal to stored ones
+func SetPolicyObjectDefaults(obj runtime.Object) {
+	if srv, ok := obj.(*server.Server); ok && srv.Spec.ProxyProtocol == "" {
+		srv.Spec.ProxyProtocol = DefaultProxyProtocol
+	}
+}
+
+// PolicyObjectSpecEqual tells if both objects are of the same type and have equal specs
+func PolicyObjectSpecEqual(a, b runtime.Object) bool {
+	switch o := a.(type) {
+	case *server.Server:
+		other, ok := b.(*server.Server)
+		return ok && equality.Semantic.DeepEqual(o.Spec, other.Spec)
+	case *policy.AuthorizationPolicy:
+		other, ok := b.(*policy.AuthorizationPolicy)
+		return ok && equality.Semantic.DeepEqual(o.Spec, other.Spec)
+	case *policy.MeshTLSAuthentication:
+		other, ok := b.(*policy.MeshTLSAuthentication)
+		return ok && equality.Semantic.DeepEqual(o.Spec, other.Spec)
+	case *policy.NetworkAuthentication:
+		other, ok := b.(*policy.NetworkAuthentication)
+		return ok && equality.Semantic.DeepEqual(o.Spec, other.Spec)
+	}
+
+	return false
+}
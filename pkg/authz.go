@@ -0,0 +1,198 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"strings"
+)
+
+// AuthorizationDetails is an authorization of a resource with the Server, route and clients it resolves to
+type AuthorizationDetails struct {
+	Namespace           string                  `json:"namespace"`
+	Route               string                  `json:"route,omitempty"`
+	Server              string                  `json:"server"`
+	ServerAuthorization string                  `json:"serverAuthorization,omitempty"`
+	AuthorizationPolicy string                  `json:"authorizationPolicy,omitempty"`
+	Port                string                  `json:"port"`
+	ProxyProtocol       string                  `json:"proxyProtocol,omitempty"`
+	Pods                []string                `json:"pods"`
+	RouteMatches        []policy.HTTPRouteMatch `json:"routeMatches,omitempty"`
+	AuthenticationKinds []string                `json:"authenticationKinds,omitempty"`
+	Authentications     []ObjectReference       `json:"authentications,omitempty"`
+	Clients             Clients                 `json:"clients"`
+}
+
+// AuthorizationDetailsForResource returns the same authorizations as AuthorizationsForResource
+// enriched with Server ports, selected pods, route matches and allowed clients
+func AuthorizationDetailsForResource(resources *K8sResources, namespace string, resource string) ([]AuthorizationDetails, error) {
+	authzs, err := AuthorizationsForResource(resources, namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := PodsForResource(resources, namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]AuthorizationDetails, 0, len(authzs))
+
+	for _, authz := range authzs {
+		srv := findServer(resources, namespace, authz.Server)
+		if srv == nil {
+			return nil, fmt.Errorf("server %s/%s not found", namespace, authz.Server)
+		}
+
+		detail := AuthorizationDetails{
+			Namespace:           namespace,
+			Route:               authz.Route,
+			Server:              authz.Server,
+			ServerAuthorization: authz.ServerAuthorization,
+			AuthorizationPolicy: authz.AuthorizationPolicy,
+			Port:                srv.Spec.Port.String(),
+			ProxyProtocol:       string(srv.Spec.ProxyProtocol),
+			Pods:                []string{},
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(srv.Spec.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create selector: %w", err)
+		}
+		for _, pod := range pods {
+			if pod.Namespace == srv.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				detail.Pods = append(detail.Pods, pod.Name)
+			}
+		}
+
+		var route *policy.HTTPRoute
+		if authz.Route != "" {
+			route = findHTTPRoute(resources, namespace, authz.Route)
+			if route != nil {
+				for _, rule := range route.Spec.Rules {
+					detail.RouteMatches = append(detail.RouteMatches, rule.Matches...)
+				}
+			}
+		}
+
+		var rule *AccessRule
+		if authz.ServerAuthorization != "" {
+			for _, serverAuthorization := range resources.ServerAuthorizations {
+				if serverAuthorization.Namespace == namespace && serverAuthorization.Name == authz.ServerAuthorization {
+					r := serverAuthorizationRule(serverAuthorization, srv)
+					rule = &r
+					break
+				}
+			}
+		} else {
+			for _, authzPolicy := range resources.AuthorizationPolicies {
+				if authzPolicy.Namespace == namespace && authzPolicy.Name == authz.AuthorizationPolicy {
+					r := authorizationPolicyRule(resources, authzPolicy, srv, route)
+					rule = &r
+					break
+				}
+			}
+		}
+
+		if rule != nil {
+			detail.Clients = rule.Clients
+			detail.Authentications = append(rule.Authentications, rule.MissingAuthentications...)
+			for _, authn := range detail.Authentications {
+				detail.AuthenticationKinds = appendUnique(detail.AuthenticationKinds, authn.Kind)
+			}
+		}
+
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+// RouteMatchString is a short form of the route match like "GET PathPrefix:/api"
+func RouteMatchString(match policy.HTTPRouteMatch) string {
+	var parts []string
+
+	if match.Method != nil {
+		parts = append(parts, string(*match.Method))
+	}
+
+	if match.Path != nil {
+		matchType := pathMatchPathPrefix
+		if match.Path.Type != nil {
+			matchType = string(*match.Path.Type)
+		}
+
+		value := "/"
+		if match.Path.Value != nil {
+			value = *match.Path.Value
+		}
+
+		parts = append(parts, matchType+":"+value)
+	}
+
+	for _, header := range match.Headers {
+		parts = append(parts, fmt.Sprintf("header:%s=%s", header.Name, header.Value))
+	}
+
+	for _, param := range match.QueryParams {
+		parts = append(parts, fmt.Sprintf("query:%s=%s", param.Name, param.Value))
+	}
+
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
+
+func findServer(resources *K8sResources, namespace, name string) *server.Server {
+	for _, srv := range resources.Servers {
+		if srv.Namespace == namespace && srv.Name == name {
+			return srv
+		}
+	}
+	return nil
+}
+
+func findHTTPRoute(resources *K8sResources, namespace, name string) *policy.HTTPRoute {
+	for _, route := range resources.HTTPRoutes {
+		if route.Namespace == namespace && route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// WorkloadAuthorizations are authorizations of a single workload
+type WorkloadAuthorizations struct {
+	Workload       ObjectReference        `json:"workload"`
+	Authorizations []AuthorizationDetails `json:"authorizations"`
+}
+
+// AuthorizationDetailsForWorkloads evaluates authorizations of every workload against the same snapshot
+func AuthorizationDetailsForWorkloads(resources *K8sResources, workloads []ObjectReference) ([]WorkloadAuthorizations, error) {
+	results := make([]WorkloadAuthorizations, 0, len(workloads))
+
+	for _, workload := range workloads {
+		resource := strings.ToLower(workload.Kind) + "/" + workload.Name
+
+		authzs, err := AuthorizationDetailsForResource(resources, workload.Namespace, resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authorizations for %s: %w", workload, err)
+		}
+
+		results = append(results, WorkloadAuthorizations{Workload: workload, Authorizations: authzs})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,232 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net"
+	"os"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+const (
+	// BaselineNamespaceLabel opts a namespace in ("enabled") or out ("disabled") of baseline policies,
+	// namespaces without the label get them when they have meshed pods
+	BaselineNamespaceLabel = "easyauth.linkerd.io/baseline"
+	BaselineEnabled        = "enabled"
+	BaselineDisabled       = "disabled"
+
+	ManagedByBaselineController = "baseline-controller"
+)
+
+// BaselineConfig describes the baseline policy set, it mirrors policies section of the helm chart values
+type BaselineConfig struct {
+	// AdminPodSelector selects pods which proxy admin port is open to Linkerd namespaces
+	AdminPodSelector    *metav1.LabelSelector `json:"adminPodSelector"`
+	LinkerdNamespaces   []string              `json:"linkerdNamespaces"`
+	IngressNamespace    string                `json:"ingressNamespace,omitempty"`
+	MonitoringNamespace string                `json:"monitoringNamespace,omitempty"`
+	ClusterNetworks     []string              `json:"clusterNetworks,omitempty"`
+	IdentityTrustDomain string                `json:"identityTrustDomain,omitempty"`
+}
+
+// ReadBaselineConfig reads and validates the baseline config file
+func ReadBaselineConfig(path string) (*BaselineConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &BaselineConfig{IdentityTrustDomain: DefaultTrustDomain}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if config.AdminPodSelector == nil {
+		return nil, fmt.Errorf("%s: adminPodSelector is required", path)
+	}
+
+	for _, cidr := range config.ClusterNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("%s: invalid network %s: %w", path, cidr, err)
+		}
+	}
+
+	return config, nil
+}
+
+// BaselineNamespaces returns namespaces that should have baseline policies, Linkerd namespaces are never included
+func BaselineNamespaces(resources *K8sResources, config *BaselineConfig, controlPlaneNamespace string) []string {
+	excluded := map[string]bool{controlPlaneNamespace: true}
+	for _, ns := range config.LinkerdNamespaces {
+		excluded[ns] = true
+	}
+
+	meshed := map[string]bool{}
+	for i := range resources.Pods.Items {
+		if k8s.IsMeshed(&resources.Pods.Items[i], controlPlaneNamespace) {
+			meshed[resources.Pods.Items[i].Namespace] = true
+		}
+	}
+
+	var namespaces []string
+	for _, ns := range resources.Namespaces.Items {
+		if excluded[ns.Name] || ns.DeletionTimestamp != nil {
+			continue
+		}
+
+		switch ns.Labels[BaselineNamespaceLabel] {
+		case BaselineDisabled:
+			continue
+		case BaselineEnabled:
+			namespaces = append(namespaces, ns.Name)
+		default:
+			if meshed[ns.Name] {
+				namespaces = append(namespaces, ns.Name)
+			}
+		}
+	}
+
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// BaselinePolicies returns the same objects that the helm chart renders for each namespace of policies.meshedApps:
+// the Server for the proxy admin port open to Linkerd, policies for ingress and monitoring namespaces and the cluster network authentication
+func BaselinePolicies(namespace string, config *BaselineConfig, controlPlaneNamespace string) []runtime.Object {
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{ManagedByLabel: ManagedByBaselineController},
+		}
+	}
+
+	namespaceIdentity := func(ns string) string {
+		return ServiceAccountIdentity("*", ns, controlPlaneNamespace, config.IdentityTrustDomain)
+	}
+
+	authnRef := func(name string) []gatewayapiv1alpha2.PolicyTargetReference {
+		return []gatewayapiv1alpha2.PolicyTargetReference{{
+			Group: k8s.PolicyAPIGroup,
+			Kind:  MeshTLSAuthenticationKind,
+			Name:  gatewayapiv1alpha2.ObjectName(name),
+		}}
+	}
+
+	namespacePolicy := func(name, authn string) *policy.AuthorizationPolicy {
+		return &policy.AuthorizationPolicy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       AuthorizationPolicyKind,
+			},
+			ObjectMeta: objectMeta(name),
+			Spec: policy.AuthorizationPolicySpec{
+				TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+					Group: "core",
+					Kind:  NamespaceKind,
+					Name:  gatewayapiv1alpha2.ObjectName(namespace),
+				},
+				RequiredAuthenticationRefs: authnRef(authn),
+			},
+		}
+	}
+
+	linkerdAuthn := &policy.MeshTLSAuthentication{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policy.SchemeGroupVersion.String(),
+			Kind:       MeshTLSAuthenticationKind,
+		},
+		ObjectMeta: objectMeta("linkerd-authn"),
+	}
+	for _, ns := range config.LinkerdNamespaces {
+		linkerdAuthn.Spec.Identities = append(linkerdAuthn.Spec.Identities, namespaceIdentity(ns))
+	}
+
+	objects := []runtime.Object{
+		&server.Server{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: server.SchemeGroupVersion.String(),
+				Kind:       ServerKind,
+			},
+			ObjectMeta: objectMeta("linkerd-admin-port"),
+			Spec: server.ServerSpec{
+				PodSelector: config.AdminPodSelector.DeepCopy(),
+				Port:        intstr.FromString(k8s.ProxyAdminPortName),
+			},
+		},
+		linkerdAuthn,
+		&policy.AuthorizationPolicy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       AuthorizationPolicyKind,
+			},
+			ObjectMeta: objectMeta("linkerd-admin-allow"),
+			Spec: policy.AuthorizationPolicySpec{
+				TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+					Group: k8s.PolicyAPIGroup,
+					Kind:  k8s.ServerKind,
+					Name:  "linkerd-admin-port",
+				},
+				RequiredAuthenticationRefs: authnRef(linkerdAuthn.Name),
+			},
+		},
+	}
+
+	if config.MonitoringNamespace != "" {
+		objects = append(objects,
+			&policy.MeshTLSAuthentication{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: policy.SchemeGroupVersion.String(),
+					Kind:       MeshTLSAuthenticationKind,
+				},
+				ObjectMeta: objectMeta("monitoring-authn"),
+				Spec: policy.MeshTLSAuthenticationSpec{
+					Identities: []string{namespaceIdentity(config.MonitoringNamespace)},
+				},
+			},
+			namespacePolicy("linkerd-monitoring-allow", "monitoring-authn"),
+		)
+	}
+
+	if config.IngressNamespace != "" {
+		objects = append(objects,
+			&policy.MeshTLSAuthentication{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: policy.SchemeGroupVersion.String(),
+					Kind:       MeshTLSAuthenticationKind,
+				},
+				ObjectMeta: objectMeta("ingress-authn"),
+				Spec: policy.MeshTLSAuthenticationSpec{
+					Identities: []string{namespaceIdentity(config.IngressNamespace)},
+				},
+			},
+			namespacePolicy("linkerd-ingress-allow", "ingress-authn"),
+		)
+	}
+
+	if len(config.ClusterNetworks) > 0 {
+		networkAuthn := &policy.NetworkAuthentication{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       NetworkAuthenticationKind,
+			},
+			ObjectMeta: objectMeta("cluster-network-authn"),
+		}
+		// policies pre-created by users may require it before anything else does
+		networkAuthn.Annotations = map[string]string{PruneExcludeAnnotation: "true"}
+		for _, cidr := range config.ClusterNetworks {
+			networkAuthn.Spec.Networks = append(networkAuthn.Spec.Networks, &policy.Network{Cidr: cidr})
+		}
+		objects = append(objects, networkAuthn)
+	}
+
+	return objects
+}
@@ -0,0 +1,515 @@
+package common
+
+import (
+	"fmt"
+	"github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"strconv"
+)
+
+const (
+	ServerWithoutPolicyRule    = "server-without-policy"
+	PolicyWithoutServerRule    = "policy-without-server"
+	ObsoleteHTTPRouteRule      = "obsolete-httproute"
+	PortWithoutServerRule      = "port-without-server"
+	ObsoleteAuthenticationRule = "obsolete-authentication"
+	LegacyMarkerRule           = "legacy-marker"
+	DenyUncoveredPortRule      = "deny-uncovered-port"
+)
+
+// EasyAuthChecks returns all easyauth checks in the order they should be reported
+func EasyAuthChecks(controlPlaneNamespace string) []Check {
+	return []Check{
+		serverWithoutPolicyCheck(),
+		policyWithoutServerCheck(),
+		obsoleteHTTPRouteCheck(),
+		portWithoutServerCheck(controlPlaneNamespace),
+		obsoleteAuthenticationCheck(),
+		legacyMarkerCheck(controlPlaneNamespace),
+		denyUncoveredPortCheck(controlPlaneNamespace),
+	}
+}
+
+func serverWithoutPolicyCheck() Check {
+	check := Check{
+		ID:          ServerWithoutPolicyRule,
+		Description: "linkerd-easyauth no Server without authorization policies",
+		Summary:     "Some servers have no authorization policies",
+		Severity:    SeverityWarning,
+		Remediation: "create an AuthorizationPolicy that targets the Server (or its HTTPRoute), or delete the Server",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, server := range resources.Servers {
+			founded, err := serverHasPolicy(resources, server)
+			if err != nil {
+				return nil, err
+			}
+
+			if !founded {
+				findings = append(findings, check.finding(
+					ObjectReference{Kind: ServerKind, Namespace: server.GetNamespace(), Name: server.GetName()},
+					"Server %s has no authorization policies", server.GetName(),
+				))
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func policyWithoutServerCheck() Check {
+	check := Check{
+		ID:          PolicyWithoutServerRule,
+		Description: "linkerd-easyauth no authorization policies without Server",
+		Summary:     "Some ServerAuthorizations and AuthorizationPolicies do not apply to any Server",
+		Severity:    SeverityWarning,
+		Remediation: "fix the policy target so it points to an existing Server, or delete the policy",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, serverAuthorization := range resources.ServerAuthorizations {
+			founded := false
+			for _, server := range resources.Servers {
+				selector, err := metav1.LabelSelectorAsSelector(serverAuthorization.Spec.Server.Selector)
+				if err != nil {
+					return nil, err
+				}
+
+				if selector.Matches(labels.Set(server.GetLabels())) {
+					founded = true
+				}
+
+				if serverAuthorization.Spec.Server.Name == server.GetName() {
+					founded = true
+				}
+			}
+
+			if !founded {
+				findings = append(findings, check.finding(
+					ObjectReference{Kind: ServerAuthorizationKind, Namespace: serverAuthorization.GetNamespace(), Name: serverAuthorization.GetName()},
+					"ServerAuthorization %s does not apply to any Server", serverAuthorization.GetName(),
+				))
+			}
+		}
+
+		for _, policy := range resources.AuthorizationPolicies {
+			founded := false
+
+			if policy.Spec.TargetRef.Kind == "Namespace" {
+				// at least one Server should exist
+				founded = len(resources.Servers) > 0
+			} else {
+				for _, server := range resources.Servers {
+					if policy.Spec.TargetRef.Kind == k8s.ServerKind && (string(policy.Spec.TargetRef.Name) == server.GetName()) {
+						founded = true
+						break
+					}
+				}
+
+				for _, httpRoute := range resources.HTTPRoutes {
+					if policy.Spec.TargetRef.Kind == k8s.HTTPRouteKind {
+						for _, server := range resources.Servers {
+							for _, targetRef := range httpRoute.Spec.ParentRefs {
+								if isServerParentRef(targetRef) && string(targetRef.Name) == server.GetName() && string(policy.Spec.TargetRef.Name) == httpRoute.GetName() {
+									founded = true
+									break
+								}
+							}
+						}
+					}
+				}
+			}
+
+			if !founded {
+				findings = append(findings, check.finding(
+					ObjectReference{Kind: AuthorizationPolicyKind, Namespace: policy.GetNamespace(), Name: policy.GetName()},
+					"Authorization Policy %s does not apply to any Server", policy.GetName(),
+				))
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func obsoleteHTTPRouteCheck() Check {
+	check := Check{
+		ID:          ObsoleteHTTPRouteRule,
+		Description: "linkerd-easyauth no obsolete HTTPRoutes",
+		Summary:     "Some HTTPRoutes have obsolete targetRef",
+		Severity:    SeverityWarning,
+		Remediation: "point the parentRef to an existing Server, or delete the HTTPRoute",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, httpRoute := range resources.HTTPRoutes {
+			for _, targetRef := range httpRoute.Spec.ParentRefs {
+				founded := false
+
+				if isServerParentRef(targetRef) {
+					for _, server := range resources.Servers {
+						if string(targetRef.Name) == server.GetName() {
+							founded = true
+							break
+						}
+					}
+				}
+
+				if !founded {
+					findings = append(findings, check.finding(
+						ObjectReference{Kind: HTTPRouteKind, Namespace: httpRoute.GetNamespace(), Name: httpRoute.GetName()},
+						"TargetRef %s in HTTPPolicy %s is obsolete (eg. doesn't apply to any Server)",
+						string(targetRef.Name),
+						httpRoute.GetName(),
+					))
+				}
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func portWithoutServerCheck(controlPlaneNamespace string) Check {
+	check := Check{
+		ID:          PortWithoutServerRule,
+		Description: "linkerd-easyauth no ports without Server",
+		Summary:     "Some pods have ports that are not covered by Server",
+		Severity:    SeverityWarning,
+		Remediation: "create a Server that selects the pod and its port",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, pod := range resources.Pods.Items {
+			if k8s.IsMeshed(&pod, controlPlaneNamespace) {
+				foundedPorts, err := checkPodsPortsForServer(resources, pod)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, port := range foundedPorts {
+					finding := check.finding(
+						ObjectReference{Kind: PodKind, Namespace: pod.Namespace, Name: pod.Name},
+						"%s -> %s:%d has no Server", pod.Name, port.container, port.port.ContainerPort,
+					)
+					finding.Port = strconv.Itoa(int(port.port.ContainerPort))
+					findings = append(findings, finding)
+				}
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func obsoleteAuthenticationCheck() Check {
+	check := Check{
+		ID:          ObsoleteAuthenticationRule,
+		Description: "linkerd-easyauth no obsolete authentications",
+		Summary:     "Some authentications are obsolete (eg. doesn't apply to any policy)",
+		Severity:    SeverityWarning,
+		Remediation: "reference the authentication from an AuthorizationPolicy, or delete it",
+		// policies may require authentications of other namespaces
+		CrossNamespace: true,
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		var authentications []ObjectReference
+		findings := []Finding{}
+
+		for _, authn := range resources.MeshTLSAuthentications {
+			authentications = append(authentications, ObjectReference{Kind: MeshTLSAuthenticationKind, Namespace: authn.GetNamespace(), Name: authn.GetName()})
+		}
+
+		for _, authn := range resources.NetworkAuthentications {
+			authentications = append(authentications, ObjectReference{Kind: NetworkAuthenticationKind, Namespace: authn.GetNamespace(), Name: authn.GetName()})
+		}
+
+		for _, authn := range authentications {
+			founded := false
+
+			for _, policy := range resources.AuthorizationPolicies {
+				if PolicyRequiresAuthentication(policy, authn) {
+					founded = true
+					break
+				}
+			}
+
+			if !founded {
+				findings = append(findings, check.finding(authn, "%s %s is obsolete", authn.Kind, authn.Name))
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func legacyMarkerCheck(controlPlaneNamespace string) Check {
+	check := Check{
+		ID:          LegacyMarkerRule,
+		Description: "linkerd-easyauth no pods with legacy markers",
+		Summary:     "Some pods are marked only by legacy keys and are not selected by easyauth Servers",
+		Severity:    SeverityWarning,
+		Remediation: fmt.Sprintf("restart the pods so the injector adds %s label", EasyAuthEnabledKey),
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, pod := range resources.Pods.Items {
+			pod := pod
+			if k8s.IsMeshed(&pod, controlPlaneNamespace) && HasOnlyLegacyEasyAuthKey(&pod) {
+				findings = append(findings, check.finding(
+					ObjectReference{Kind: PodKind, Namespace: pod.Namespace, Name: pod.Name},
+					"%s is marked only by a legacy key", pod.Name,
+				))
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func denyUncoveredPortCheck(controlPlaneNamespace string) Check {
+	check := Check{
+		ID:          DenyUncoveredPortRule,
+		Description: "linkerd-easyauth no unauthorized ports with deny default policy",
+		Summary:     "Some pods have deny default inbound policy and ports without Server and AuthorizationPolicy, their traffic is rejected",
+		Severity:    SeverityWarning,
+		Remediation: fmt.Sprintf("create a Server and an AuthorizationPolicy for the port, or set %s: %s on the namespace first", DefaultPolicyAnnotation, AuditPolicy),
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, pod := range resources.Pods.Items {
+			pod := pod
+			if !k8s.IsMeshed(&pod, controlPlaneNamespace) || podDefaultPolicy(&pod, resources.Namespace(pod.Namespace)) != DenyPolicy {
+				continue
+			}
+
+			for _, container := range pod.Spec.Containers {
+				if container.Name == k8s.ProxyContainerName {
+					continue
+				}
+
+				for _, port := range container.Ports {
+					servers, err := serversForPort(resources.Servers, pod, port)
+					if err != nil {
+						return nil, err
+					}
+
+					authorized := false
+					for _, server := range servers {
+						if authorized, err = serverHasPolicy(resources, server); err != nil {
+							return nil, err
+						}
+						if authorized {
+							break
+						}
+					}
+
+					if authorized {
+						continue
+					}
+
+					var finding Finding
+					if len(servers) == 0 {
+						finding = check.finding(
+							ObjectReference{Kind: PodKind, Namespace: pod.Namespace, Name: pod.Name},
+							"%s -> %s:%d has no Server and is denied", pod.Name, container.Name, port.ContainerPort,
+						)
+					} else {
+						finding = check.finding(
+							ObjectReference{Kind: PodKind, Namespace: pod.Namespace, Name: pod.Name},
+							"%s -> %s:%d has no authorization policies for Server %s and is denied", pod.Name, container.Name, port.ContainerPort, servers[0].GetName(),
+						)
+					}
+					finding.Port = strconv.Itoa(int(port.ContainerPort))
+					findings = append(findings, finding)
+				}
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+// podDefaultPolicy returns the default inbound policy that the pod has or gets from the injector on restart,
+// the pod annotation takes precedence over the easyauth and then the Linkerd namespace annotation
+func podDefaultPolicy(pod *v1.Pod, namespace *v1.Namespace) string {
+	if policy := pod.Annotations[DefaultInboundPolicyAnnotation]; policy != "" {
+		return policy
+	}
+
+	if namespace == nil {
+		return ""
+	}
+
+	if policy, err := NamespaceDefaultPolicy(namespace); err == nil && policy != "" {
+		return policy
+	}
+
+	return namespace.Annotations[DefaultInboundPolicyAnnotation]
+}
+
+// isServerParentRef is false for parentRefs without kind, it defaults to Gateway
+// and is not set in manifests that are not defaulted by the API server
+func isServerParentRef(ref gatewayapiv1alpha2.ParentReference) bool {
+	return ref.Kind != nil && *ref.Kind == k8s.ServerKind
+}
+
+type uncoveredPort struct {
+	container string
+	port      v1.ContainerPort
+}
+
+func checkPodsPortsForServer(resources *K8sResources, pod v1.Pod) ([]uncoveredPort, error) {
+	portsWOServers := []uncoveredPort{}
+	foundedPorts := map[int32]bool{}
+	for _, service := range resources.Services.Items {
+		if service.Namespace != pod.Namespace {
+			continue
+		}
+
+		if len(service.Spec.Selector) > 0 && labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			for _, svcPort := range service.Spec.Ports {
+				for _, container := range pod.Spec.Containers {
+					for _, podPort := range container.Ports {
+						var matchedPort v1.ContainerPort
+						if svcPort.TargetPort.IntValue() > 0 {
+							if int(podPort.ContainerPort) == svcPort.TargetPort.IntValue() {
+								matchedPort = podPort
+							}
+						} else {
+							if podPort.Name == svcPort.TargetPort.String() {
+								matchedPort = podPort
+							}
+						}
+
+						if matchedPort.ContainerPort > 0 {
+							founded, err := findServerForPort(resources.Servers, pod, matchedPort)
+							if err != nil {
+								return nil, err
+							}
+
+							if !founded {
+								if !foundedPorts[matchedPort.ContainerPort] {
+									foundedPorts[matchedPort.ContainerPort] = true
+									portsWOServers = append(portsWOServers, uncoveredPort{container: container.Name, port: matchedPort})
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return portsWOServers, nil
+}
+
+func findServerForPort(servers []*v1beta1.Server, pod v1.Pod, matchedPort v1.ContainerPort) (bool, error) {
+	matched, err := serversForPort(servers, pod, matchedPort)
+	if err != nil {
+		return false, err
+	}
+	return len(matched) > 0, nil
+}
+
+func serversForPort(servers []*v1beta1.Server, pod v1.Pod, matchedPort v1.ContainerPort) ([]*v1beta1.Server, error) {
+	var matched []*v1beta1.Server
+
+	for _, server := range servers {
+		// Servers select pods of their own namespace only
+		if server.Namespace != pod.Namespace {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(server.Spec.PodSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		if selector.Matches(labels.Set(pod.Labels)) {
+			if server.Spec.Port.IntValue() > 0 {
+				if int(matchedPort.ContainerPort) == server.Spec.Port.IntValue() {
+					matched = append(matched, server)
+				}
+			} else {
+				if matchedPort.Name == server.Spec.Port.String() {
+					matched = append(matched, server)
+				}
+			}
+		}
+	}
+	return matched, nil
+}
+
+// serverHasPolicy tells if a ServerAuthorization or an AuthorizationPolicy applies to the Server directly,
+// through its namespace or through an HTTPRoute
+func serverHasPolicy(resources *K8sResources, server *v1beta1.Server) (bool, error) {
+	for _, serverAuthorization := range resources.ServerAuthorizations {
+		selector, err := metav1.LabelSelectorAsSelector(serverAuthorization.Spec.Server.Selector)
+		if err != nil {
+			return false, err
+		}
+
+		if selector.Matches(labels.Set(server.GetLabels())) {
+			return true, nil
+		}
+
+		if serverAuthorization.Spec.Server.Name == server.GetName() {
+			return true, nil
+		}
+	}
+
+	for _, policy := range resources.AuthorizationPolicies {
+		// namespaced policies applies on each server
+		if policy.Spec.TargetRef.Kind == "Namespace" && policy.GetNamespace() == server.Namespace {
+			return true, nil
+		}
+
+		if policy.Spec.TargetRef.Kind == k8s.ServerKind && string(policy.Spec.TargetRef.Name) == server.GetName() {
+			return true, nil
+		}
+
+		if policy.Spec.TargetRef.Kind == k8s.HTTPRouteKind {
+			for _, httpRoute := range resources.HTTPRoutes {
+				for _, targetRef := range httpRoute.Spec.ParentRefs {
+					if isServerParentRef(targetRef) && string(targetRef.Name) == server.GetName() && string(policy.Spec.TargetRef.Name) == httpRoute.GetName() {
+						return true, nil
+					}
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"context"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"sort"
+	"testing"
+)
+
+func findingObjects(findings []Finding) []string {
+	objects := []string{}
+	for _, finding := range findings {
+		object := finding.Object.String()
+		if finding.Port != "" {
+			object += ":" + finding.Port
+		}
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+func TestChecks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		check     Check
+		resources func(r *K8sResources)
+		namespace string
+		expected  []string
+	}{
+		{
+			name:  "server with policy",
+			check: serverWithoutPolicyCheck(),
+			resources: func(r *K8sResources) {
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080))
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("a", "web", ServerKind, "web"))
+			},
+			expected: []string{},
+		},
+		{
+			name:  "server without policy is not hidden by a policy in another namespace",
+			check: serverWithoutPolicyCheck(),
+			resources: func(r *K8sResources) {
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080), testServer("b", "web", "web", 8080))
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("b", "web", ServerKind, "web"))
+			},
+			expected: []string{"a/Server/web"},
+		},
+		{
+			name:  "server without policy in the fetched namespace only",
+			check: serverWithoutPolicyCheck(),
+			resources: func(r *K8sResources) {
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080), testServer("b", "api", "api", 8080))
+			},
+			namespace: "b",
+			expected:  []string{"b/Server/api"},
+		},
+		{
+			name:  "namespace policy covers all servers",
+			check: serverWithoutPolicyCheck(),
+			resources: func(r *K8sResources) {
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080))
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("a", "all", NamespaceKind, "a"))
+			},
+			expected: []string{},
+		},
+		{
+			name:  "policy without server",
+			check: policyWithoutServerCheck(),
+			resources: func(r *K8sResources) {
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("a", "web", ServerKind, "web"))
+			},
+			expected: []string{"a/AuthorizationPolicy/web"},
+		},
+		{
+			name:  "httproute with dangling and defaulted parentRefs",
+			check: obsoleteHTTPRouteCheck(),
+			resources: func(r *K8sResources) {
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080))
+				r.HTTPRoutes = append(r.HTTPRoutes,
+					testHTTPRoute("a", "live", testServerParentRef("web")),
+					testHTTPRoute("a", "dangling", testServerParentRef("gone")),
+					// kind defaults to Gateway, manifests are not defaulted
+					testHTTPRoute("a", "gateway", gatewayapiv1alpha2.ParentReference{Name: "web"}),
+				)
+			},
+			expected: []string{"a/HTTPRoute/dangling", "a/HTTPRoute/gateway"},
+		},
+		{
+			name:  "port without server",
+			check: portWithoutServerCheck("linkerd"),
+			resources: func(r *K8sResources) {
+				r.Pods.Items = append(r.Pods.Items, testMeshedPod("a", "web", 8080), testMeshedPod("a", "api", 8080))
+				r.Services.Items = append(r.Services.Items, testService("a", "web", 8080), testService("a", "api", 8080))
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080))
+			},
+			expected: []string{"a/Pod/api:8080"},
+		},
+		{
+			name:  "authentication without policy",
+			check: obsoleteAuthenticationCheck(),
+			resources: func(r *K8sResources) {
+				r.MeshTLSAuthentications = append(r.MeshTLSAuthentications, testMeshTLSAuthentication("a", "clients"))
+			},
+			expected: []string{"a/MeshTLSAuthentication/clients"},
+		},
+		{
+			name:  "legacy marker",
+			check: legacyMarkerCheck("linkerd"),
+			resources: func(r *K8sResources) {
+				legacy := testMeshedPod("a", "legacy")
+				legacy.Labels[LegacyEasyAuthKeys[0]] = "true"
+				current := testMeshedPod("a", "current")
+				current.Labels[EasyAuthEnabledKey] = EasyAuthEnabledValue
+				current.Labels[LegacyEasyAuthKeys[0]] = "true"
+				r.Pods.Items = append(r.Pods.Items, legacy, current)
+			},
+			expected: []string{"a/Pod/legacy"},
+		},
+		{
+			name:  "ports without server or policy in deny namespace",
+			check: denyUncoveredPortCheck("linkerd"),
+			resources: func(r *K8sResources) {
+				r.Namespaces.Items = append(r.Namespaces.Items,
+					testNamespace("a", map[string]string{DefaultPolicyAnnotation: DenyPolicy}),
+					testNamespace("b", nil),
+				)
+				optOut := testMeshedPod("a", "opt-out", 8080)
+				optOut.Annotations = map[string]string{DefaultInboundPolicyAnnotation: AuditPolicy}
+				r.Pods.Items = append(r.Pods.Items,
+					testMeshedPod("a", "web", 8080, 9090, 9091), optOut, testMeshedPod("b", "web", 8080))
+				r.Servers = append(r.Servers, testServer("a", "web", "web", 8080), testServer("a", "web-metrics", "web", 9090))
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("a", "web", ServerKind, "web"))
+			},
+			expected: []string{"a/Pod/web:9090", "a/Pod/web:9091"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			resources := testResources()
+			tc.resources(resources)
+
+			source := &StaticSource{Resources: resources}
+			fetched, err := source.Fetch(context.Background(), tc.namespace)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			findings, err := tc.check.RunPerNamespace(fetched)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assertStrings(t, "findings", tc.expected, findingObjects(findings))
+
+			for _, finding := range findings {
+				if finding.RuleID != tc.check.ID {
+					t.Errorf("expected rule %s, got %s", tc.check.ID, finding.RuleID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"net"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"strconv"
+	"strings"
+)
+
+const (
+	EasyAuthPolicyKind = "EasyAuthPolicy"
+
+	ManagedByEasyAuthPolicyController = "easyauthpolicy-controller"
+
+	// EasyAuthPolicyNamePrefix starts names of compiled objects, so Servers don't take <workload>-<port> names of the servers controller
+	EasyAuthPolicyNamePrefix = "easyauth-"
+
+	// EasyAuthPolicyReady is the status condition that tells whether the policy is compiled and applied
+	EasyAuthPolicyReady = "Ready"
+)
+
+var (
+	EasyAuthGroupVersion   = schema.GroupVersion{Group: "easyauth.linkerd.io", Version: "v1alpha1"}
+	EasyAuthPolicyResource = EasyAuthGroupVersion.WithResource("easyauthpolicies")
+)
+
+// EasyAuthPolicy allows namespaces, service accounts and networks to call ports of selected pods,
+// it is compiled to Servers, AuthorizationPolicies and authentications
+type EasyAuthPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              EasyAuthPolicySpec   `json:"spec"`
+	Status            EasyAuthPolicyStatus `json:"status,omitempty"`
+}
+
+type EasyAuthPolicySpec struct {
+	// PodSelector selects pods which ports are protected
+	PodSelector *metav1.LabelSelector `json:"podSelector"`
+	// Ports are container port names or numbers with optional proxy protocols, e.g. "http" or "grpc:gRPC"
+	Ports []string `json:"ports"`
+	// Namespaces which service accounts are allowed
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ServiceAccounts are allowed service accounts, "name" for the policy namespace or "namespace/name"
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// Networks are allowed CIDRs, e.g. for unmeshed clients
+	Networks []string `json:"networks,omitempty"`
+}
+
+type EasyAuthPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// CompileEasyAuthPolicy returns a Server per port with AuthorizationPolicies that admit clients of the EasyAuthPolicy,
+// all objects are owned by the EasyAuthPolicy and named after it with EasyAuthPolicyNamePrefix
+func CompileEasyAuthPolicy(p *EasyAuthPolicy) ([]runtime.Object, error) {
+	if p.Spec.PodSelector == nil {
+		return nil, errors.New("podSelector is required")
+	}
+
+	ports, err := ParseServersAnnotation(strings.Join(p.Spec.Ports, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, errors.New("at least one port is required")
+	}
+
+	if len(p.Spec.Namespaces) == 0 && len(p.Spec.ServiceAccounts) == 0 && len(p.Spec.Networks) == 0 {
+		return nil, errors.New("at least one of namespaces, serviceAccounts and networks is required")
+	}
+
+	owner := *metav1.NewControllerRef(p, EasyAuthGroupVersion.WithKind(EasyAuthPolicyKind))
+	objectMeta := func(suffix string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace:       p.Namespace,
+			Name:            strings.ToLower(EasyAuthPolicyNamePrefix + p.Name + suffix),
+			Labels:          map[string]string{ManagedByLabel: ManagedByEasyAuthPolicyController},
+			OwnerReferences: []metav1.OwnerReference{owner},
+		}
+	}
+
+	var objects []runtime.Object
+	var authnRefs [][]gatewayapiv1alpha2.PolicyTargetReference
+	var authnSuffixes []string
+
+	if len(p.Spec.Namespaces) > 0 || len(p.Spec.ServiceAccounts) > 0 {
+		authn := &policy.MeshTLSAuthentication{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       MeshTLSAuthenticationKind,
+			},
+			ObjectMeta: objectMeta(""),
+		}
+
+		for _, ns := range p.Spec.Namespaces {
+			authn.Spec.IdentityRefs = append(authn.Spec.IdentityRefs, gatewayapiv1alpha2.PolicyTargetReference{
+				Kind: NamespaceKind,
+				Name: gatewayapiv1alpha2.ObjectName(ns),
+			})
+		}
+
+		for _, sa := range p.Spec.ServiceAccounts {
+			identityRef := gatewayapiv1alpha2.PolicyTargetReference{Kind: ServiceAccountKind}
+			if ns, name, ok := strings.Cut(sa, "/"); ok {
+				namespace := gatewayapiv1alpha2.Namespace(ns)
+				identityRef.Namespace = &namespace
+				identityRef.Name = gatewayapiv1alpha2.ObjectName(name)
+			} else {
+				identityRef.Name = gatewayapiv1alpha2.ObjectName(sa)
+			}
+			authn.Spec.IdentityRefs = append(authn.Spec.IdentityRefs, identityRef)
+		}
+
+		objects = append(objects, authn)
+		authnRefs = append(authnRefs, []gatewayapiv1alpha2.PolicyTargetReference{{
+			Group: k8s.PolicyAPIGroup,
+			Kind:  MeshTLSAuthenticationKind,
+			Name:  gatewayapiv1alpha2.ObjectName(authn.Name),
+		}})
+		authnSuffixes = append(authnSuffixes, "")
+	}
+
+	if len(p.Spec.Networks) > 0 {
+		authn := &policy.NetworkAuthentication{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       NetworkAuthenticationKind,
+			},
+			ObjectMeta: objectMeta("-network"),
+		}
+
+		for _, cidr := range p.Spec.Networks {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("invalid network %s: %w", cidr, err)
+			}
+			authn.Spec.Networks = append(authn.Spec.Networks, &policy.Network{Cidr: cidr})
+		}
+
+		objects = append(objects, authn)
+		authnRefs = append(authnRefs, []gatewayapiv1alpha2.PolicyTargetReference{{
+			Group: k8s.PolicyAPIGroup,
+			Kind:  NetworkAuthenticationKind,
+			Name:  gatewayapiv1alpha2.ObjectName(authn.Name),
+		}})
+		authnSuffixes = append(authnSuffixes, "-network")
+	}
+
+	for _, port := range ports {
+		portName := port.Port.Name
+		if portName == "" {
+			portName = strconv.Itoa(int(port.Port.ContainerPort))
+		}
+
+		srv := newServer(p.Namespace, p.Name, p.Spec.PodSelector.DeepCopy(), port.Port)
+		srv.ObjectMeta = objectMeta("-" + portName)
+		srv.Spec.ProxyProtocol = port.ProxyProtocol
+		objects = append(objects, srv)
+
+		// required authentications are all required at once, so mesh and network clients need separate policies
+		for i, refs := range authnRefs {
+			objects = append(objects, &policy.AuthorizationPolicy{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: policy.SchemeGroupVersion.String(),
+					Kind:       AuthorizationPolicyKind,
+				},
+				ObjectMeta: objectMeta("-" + portName + authnSuffixes[i]),
+				Spec: policy.AuthorizationPolicySpec{
+					TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+						Group: k8s.PolicyAPIGroup,
+						Kind:  k8s.ServerKind,
+						Name:  gatewayapiv1alpha2.ObjectName(srv.Name),
+					},
+					RequiredAuthenticationRefs: refs,
+				},
+			})
+		}
+	}
+
+	return objects, nil
+}
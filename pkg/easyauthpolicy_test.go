@@ -0,0 +1,191 @@
+package common
+
+import (
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testEasyAuthPolicy(spec EasyAuthPolicySpec) *EasyAuthPolicy {
+	if spec.PodSelector == nil {
+		spec.PodSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	}
+
+	return &EasyAuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "web", UID: types.UID("uid")},
+		Spec:       spec,
+	}
+}
+
+// describeObjects returns "Kind/name spec" lines that are compared by tests
+func describeObjects(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+
+	refs := func(refs []gatewayRef) string {
+		names := []string{}
+		for _, ref := range refs {
+			names = append(names, ref.kind+"/"+ref.name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	var lines []string
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *server.Server:
+			line := ServerKind + "/" + o.Name + " port=" + o.Spec.Port.String()
+			if o.Spec.ProxyProtocol != "" {
+				line += " protocol=" + o.Spec.ProxyProtocol
+			}
+			lines = append(lines, line)
+		case *policy.AuthorizationPolicy:
+			var authnRefs []gatewayRef
+			for _, ref := range o.Spec.RequiredAuthenticationRefs {
+				authnRefs = append(authnRefs, gatewayRef{kind: string(ref.Kind), name: string(ref.Name)})
+			}
+			lines = append(lines, AuthorizationPolicyKind+"/"+o.Name+" target="+string(o.Spec.TargetRef.Name)+" authn="+refs(authnRefs))
+		case *policy.MeshTLSAuthentication:
+			var identityRefs []gatewayRef
+			for _, ref := range o.Spec.IdentityRefs {
+				name := string(ref.Name)
+				if ref.Namespace != nil {
+					name = string(*ref.Namespace) + "/" + name
+				}
+				identityRefs = append(identityRefs, gatewayRef{kind: string(ref.Kind), name: name})
+			}
+			lines = append(lines, MeshTLSAuthenticationKind+"/"+o.Name+" identities="+refs(identityRefs))
+		case *policy.NetworkAuthentication:
+			var networks []string
+			for _, network := range o.Spec.Networks {
+				networks = append(networks, network.Cidr)
+			}
+			lines = append(lines, NetworkAuthenticationKind+"/"+o.Name+" networks="+strings.Join(networks, ","))
+		default:
+			t.Fatalf("unexpected object %T", obj)
+		}
+
+		objMeta, ok := obj.(metav1.Object)
+		if !ok {
+			t.Fatalf("%T has no metadata", obj)
+		}
+		if objMeta.GetNamespace() != "app" || objMeta.GetLabels()[ManagedByLabel] != ManagedByEasyAuthPolicyController {
+			t.Errorf("%s/%s: unexpected namespace or labels %v", objMeta.GetNamespace(), objMeta.GetName(), objMeta.GetLabels())
+		}
+		if controller := metav1.GetControllerOf(objMeta); controller == nil || controller.UID != "uid" || controller.Kind != EasyAuthPolicyKind {
+			t.Errorf("%s: expected to be controlled by the EasyAuthPolicy, got %v", objMeta.GetName(), controller)
+		}
+	}
+
+	sort.Strings(lines)
+	return lines
+}
+
+type gatewayRef struct {
+	kind string
+	name string
+}
+
+func TestCompileEasyAuthPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     EasyAuthPolicySpec
+		expected []string
+		err      string
+	}{
+		{
+			name: "service accounts and namespaces",
+			spec: EasyAuthPolicySpec{
+				Ports:           []string{"http", "9090:gRPC"},
+				Namespaces:      []string{"ingress"},
+				ServiceAccounts: []string{"client", "other/caller"},
+			},
+			expected: []string{
+				"AuthorizationPolicy/easyauth-web-9090 target=easyauth-web-9090 authn=MeshTLSAuthentication/easyauth-web",
+				"AuthorizationPolicy/easyauth-web-http target=easyauth-web-http authn=MeshTLSAuthentication/easyauth-web",
+				"MeshTLSAuthentication/easyauth-web identities=Namespace/ingress,ServiceAccount/client,ServiceAccount/other/caller",
+				"Server/easyauth-web-9090 port=9090 protocol=gRPC",
+				"Server/easyauth-web-http port=http",
+			},
+		},
+		{
+			name: "networks only",
+			spec: EasyAuthPolicySpec{
+				Ports:    []string{"http"},
+				Networks: []string{"10.0.0.0/8", "192.168.0.0/16"},
+			},
+			expected: []string{
+				"AuthorizationPolicy/easyauth-web-http-network target=easyauth-web-http authn=NetworkAuthentication/easyauth-web-network",
+				"NetworkAuthentication/easyauth-web-network networks=10.0.0.0/8,192.168.0.0/16",
+				"Server/easyauth-web-http port=http",
+			},
+		},
+		{
+			// required authentications are all required at once, so mesh and network clients get separate policies
+			name: "mesh and network clients",
+			spec: EasyAuthPolicySpec{
+				Ports:           []string{"HTTP"},
+				ServiceAccounts: []string{"client"},
+				Networks:        []string{"10.0.0.0/8"},
+			},
+			expected: []string{
+				"AuthorizationPolicy/easyauth-web-http target=easyauth-web-http authn=MeshTLSAuthentication/easyauth-web",
+				"AuthorizationPolicy/easyauth-web-http-network target=easyauth-web-http authn=NetworkAuthentication/easyauth-web-network",
+				"MeshTLSAuthentication/easyauth-web identities=ServiceAccount/client",
+				"NetworkAuthentication/easyauth-web-network networks=10.0.0.0/8",
+				"Server/easyauth-web-http port=HTTP",
+			},
+		},
+		{
+			name: "no clients",
+			spec: EasyAuthPolicySpec{Ports: []string{"http"}},
+			err:  "at least one of namespaces, serviceAccounts and networks is required",
+		},
+		{
+			name: "no ports",
+			spec: EasyAuthPolicySpec{Namespaces: []string{"ingress"}},
+			err:  "at least one port is required",
+		},
+		{
+			name: "invalid network",
+			spec: EasyAuthPolicySpec{Ports: []string{"http"}, Networks: []string{"10.0.0.0"}},
+			err:  "invalid network 10.0.0.0",
+		},
+		{
+			name: "invalid proxy protocol",
+			spec: EasyAuthPolicySpec{Ports: []string{"http:h3"}, Namespaces: []string{"ingress"}},
+			err:  "unsupported proxy protocol",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			objects, err := CompileEasyAuthPolicy(testEasyAuthPolicy(tc.spec))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assertStrings(t, "objects", tc.expected, describeObjects(t, objects))
+		})
+	}
+}
+
+func TestCompileEasyAuthPolicyRequiresPodSelector(t *testing.T) {
+	p := testEasyAuthPolicy(EasyAuthPolicySpec{Ports: []string{"http"}, Namespaces: []string{"ingress"}})
+	p.Spec.PodSelector = nil
+
+	if _, err := CompileEasyAuthPolicy(p); err == nil {
+		t.Fatal("expected an error for a policy without podSelector")
+	}
+}
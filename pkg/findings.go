@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+)
+
+// Severity is how bad a finding is
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// ObjectReference points to the Kubernetes object that a finding is about
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (r ObjectReference) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Namespace, r.Kind, r.Name)
+}
+
+// Finding is a single problem reported by a check
+type Finding struct {
+	RuleID      string          `json:"ruleID"`
+	Severity    Severity        `json:"severity"`
+	Object      ObjectReference `json:"object"`
+	Port        string          `json:"port,omitempty"`
+	Message     string          `json:"message"`
+	Remediation string          `json:"remediation,omitempty"`
+}
+
+// Check inspects fetched resources and reports findings for a single rule
+type Check struct {
+	ID          string
+	Description string
+	Summary     string
+	Severity    Severity
+	Remediation string
+	// CrossNamespace checks resolve references by namespace themselves and see the whole snapshot
+	CrossNamespace bool
+	Run            func(resources *K8sResources) ([]Finding, error)
+}
+
+// RunPerNamespace runs the check against each namespace of resources separately: checks match objects by name,
+// so in a snapshot of several namespaces a policy in one namespace would hide a finding about a Server in another one
+func (c Check) RunPerNamespace(resources *K8sResources) ([]Finding, error) {
+	namespaces := resources.ObjectNamespaces()
+	if c.CrossNamespace || len(namespaces) <= 1 {
+		return c.Run(resources)
+	}
+
+	findings := []Finding{}
+	for _, namespace := range namespaces {
+		nsFindings, err := c.Run(resources.InNamespace(namespace))
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, nsFindings...)
+	}
+
+	return findings, nil
+}
+
+func (c Check) finding(object ObjectReference, format string, args ...interface{}) Finding {
+	return Finding{
+		RuleID:      c.ID,
+		Severity:    c.Severity,
+		Object:      object,
+		Message:     fmt.Sprintf(format, args...),
+		Remediation: c.Remediation,
+	}
+}
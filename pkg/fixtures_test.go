@@ -0,0 +1,137 @@
+package common
+
+import (
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"strings"
+	"testing"
+)
+
+// fixtures shared by tests of the package
+
+func testResources() *K8sResources {
+	return &K8sResources{
+		Namespaces:   &v1.NamespaceList{},
+		Pods:         &v1.PodList{},
+		Services:     &v1.ServiceList{},
+		Deployments:  &appsv1.DeploymentList{},
+		StatefulSets: &appsv1.StatefulSetList{},
+		DaemonSets:   &appsv1.DaemonSetList{},
+	}
+}
+
+func testNamespace(name string, annotations map[string]string) v1.Namespace {
+	return v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func testMeshedPod(namespace, name string, ports ...int32) v1.Pod {
+	container := v1.Container{Name: "app"}
+	for _, port := range ports {
+		container.Ports = append(container.Ports, v1.ContainerPort{ContainerPort: port})
+	}
+
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{"app": name, k8s.ControllerNSLabel: "linkerd"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{container, {Name: k8s.ProxyContainerName}},
+		},
+	}
+}
+
+func testService(namespace, name string, port int) v1.Service {
+	return v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports:    []v1.ServicePort{{Port: int32(port), TargetPort: intstr.FromInt(port)}},
+		},
+	}
+}
+
+func testServer(namespace, name, app string, port int) *server.Server {
+	return &server.Server{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: server.ServerSpec{
+			PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			Port:        intstr.FromInt(port),
+		},
+	}
+}
+
+func testPolicy(namespace, name, kind, target string) *policy.AuthorizationPolicy {
+	return &policy.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: policy.AuthorizationPolicySpec{
+			TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+				Group: k8s.PolicyAPIGroup,
+				Kind:  gatewayapiv1alpha2.Kind(kind),
+				Name:  gatewayapiv1alpha2.ObjectName(target),
+			},
+		},
+	}
+}
+
+func testHTTPRoute(namespace, name string, parentRefs ...gatewayapiv1alpha2.ParentReference) *policy.HTTPRoute {
+	route := &policy.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	route.Spec.ParentRefs = parentRefs
+	return route
+}
+
+func testServerParentRef(name string) gatewayapiv1alpha2.ParentReference {
+	kind := gatewayapiv1alpha2.Kind(k8s.ServerKind)
+	return gatewayapiv1alpha2.ParentReference{Kind: &kind, Name: gatewayapiv1alpha2.ObjectName(name)}
+}
+
+func testServerAuthorization(namespace, name, server string, client saz.Client) *saz.ServerAuthorization {
+	return &saz.ServerAuthorization{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: saz.ServerAuthorizationSpec{
+			Server: saz.Server{Name: server},
+			Client: client,
+		},
+	}
+}
+
+func testMeshTLSAuthentication(namespace, name string, identities ...string) *policy.MeshTLSAuthentication {
+	return &policy.MeshTLSAuthentication{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       policy.MeshTLSAuthenticationSpec{Identities: identities},
+	}
+}
+
+func testNetworkAuthentication(namespace, name string, cidrs ...string) *policy.NetworkAuthentication {
+	authn := &policy.NetworkAuthentication{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	for _, cidr := range cidrs {
+		authn.Spec.Networks = append(authn.Spec.Networks, &policy.Network{Cidr: cidr})
+	}
+	return authn
+}
+
+func testClient(namespace, serviceAccount string, ips ...string) Client {
+	return Client{
+		Namespace:      namespace,
+		ServiceAccount: serviceAccount,
+		Identity:       ServiceAccountIdentity(serviceAccount, namespace, "linkerd", DefaultTrustDomain),
+		IPs:            ips,
+	}
+}
+
+// assertStrings compares sorted descriptions of objects
+func assertStrings(t *testing.T, what string, expected, actual []string) {
+	t.Helper()
+
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %s %v, got %v", what, expected, actual)
+	}
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ServerTypeLabel marks Servers by their purpose, see the README
+	ServerTypeLabel  = "linkerd.io/server-type"
+	ServerTypeCommon = "common"
+)
+
+// GenerateServers returns Servers for container ports of meshed pods that are exposed by Services
+// but not covered by any Server, pods are selected by the selector of their owning workload
+func GenerateServers(resources *K8sResources, controlPlaneNamespace string) ([]*server.Server, error) {
+	generated := map[string]*server.Server{}
+
+	for _, pod := range resources.Pods.Items {
+		if !k8s.IsMeshed(&pod, controlPlaneNamespace) {
+			continue
+		}
+
+		ports, err := checkPodsPortsForServer(resources, pod)
+		if err != nil {
+			return nil, err
+		}
+		if len(ports) == 0 {
+			continue
+		}
+
+		owner, selector := podOwnerSelector(resources, pod)
+		if selector == nil {
+			return nil, fmt.Errorf("pod %s/%s has neither an owning workload nor labels to select it", pod.Namespace, pod.Name)
+		}
+
+		for _, port := range ports {
+			srv := newServer(pod.Namespace, owner, selector, port.port)
+
+			key := srv.Namespace + "/" + srv.Name
+			if _, ok := generated[key]; !ok {
+				generated[key] = srv
+			}
+		}
+	}
+
+	servers := make([]*server.Server, 0, len(generated))
+	for _, srv := range generated {
+		servers = append(servers, srv)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].Namespace != servers[j].Namespace {
+			return servers[i].Namespace < servers[j].Namespace
+		}
+		return servers[i].Name < servers[j].Name
+	})
+
+	return servers, nil
+}
+
+func newServer(namespace, owner string, selector *metav1.LabelSelector, port v1.ContainerPort) *server.Server {
+	serverPort := intstr.FromInt(int(port.ContainerPort))
+	portName := strconv.Itoa(int(port.ContainerPort))
+	if port.Name != "" {
+		serverPort = intstr.FromString(port.Name)
+		portName = port.Name
+	}
+
+	return &server.Server{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: server.SchemeGroupVersion.String(),
+			Kind:       ServerKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      strings.ToLower(owner + "-" + portName),
+			Labels: map[string]string{
+				ServerTypeLabel: ServerTypeCommon,
+			},
+		},
+		Spec: server.ServerSpec{
+			PodSelector: selector,
+			Port:        serverPort,
+		},
+	}
+}
+
+// podOwnerSelector returns the name and the selector of the deployment, statefulset or daemonset that owns the pod,
+// standalone pods are selected by their own labels
+func podOwnerSelector(resources *K8sResources, pod v1.Pod) (string, *metav1.LabelSelector) {
+	matches := func(meta metav1.Object, selector *metav1.LabelSelector) bool {
+		if meta.GetNamespace() != pod.Namespace || selector == nil {
+			return false
+		}
+
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		return err == nil && !s.Empty() && s.Matches(labels.Set(pod.Labels))
+	}
+
+	if resources.Deployments != nil {
+		for i, obj := range resources.Deployments.Items {
+			if matches(&resources.Deployments.Items[i], obj.Spec.Selector) {
+				return obj.Name, obj.Spec.Selector.DeepCopy()
+			}
+		}
+	}
+
+	if resources.StatefulSets != nil {
+		for i, obj := range resources.StatefulSets.Items {
+			if matches(&resources.StatefulSets.Items[i], obj.Spec.Selector) {
+				return obj.Name, obj.Spec.Selector.DeepCopy()
+			}
+		}
+	}
+
+	if resources.DaemonSets != nil {
+		for i, obj := range resources.DaemonSets.Items {
+			if matches(&resources.DaemonSets.Items[i], obj.Spec.Selector) {
+				return obj.Name, obj.Spec.Selector.DeepCopy()
+			}
+		}
+	}
+
+	if len(pod.Labels) == 0 {
+		return pod.Name, nil
+	}
+
+	matchLabels := map[string]string{}
+	for k, v := range pod.Labels {
+		// pod-template-hash and similar labels change on every rollout
+		if k == "pod-template-hash" || k == "controller-revision-hash" || k == "statefulset.kubernetes.io/pod-name" {
+			continue
+		}
+		matchLabels[k] = v
+	}
+
+	return pod.Name, &metav1.LabelSelector{MatchLabels: matchLabels}
+}
+
+// GeneratedPolicy is an AuthorizationPolicy for a Server with the MeshTLSAuthentication that it requires
+type GeneratedPolicy struct {
+	Authentication *policy.MeshTLSAuthentication
+	Policy         *policy.AuthorizationPolicy
+}
+
+// GeneratePolicies returns AuthorizationPolicies that admit exactly the client identities observed for each Server
+func GeneratePolicies(traffic []ObservedTraffic) []GeneratedPolicy {
+	var generated []GeneratedPolicy
+
+	for _, observed := range traffic {
+		if len(observed.Identities) == 0 {
+			continue
+		}
+
+		authn := &policy.MeshTLSAuthentication{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       MeshTLSAuthenticationKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: observed.Server.Namespace,
+				Name:      observed.Server.Name + "-clients",
+			},
+			Spec: policy.MeshTLSAuthenticationSpec{
+				Identities: observed.SortedIdentities(),
+			},
+		}
+
+		authzPolicy := &policy.AuthorizationPolicy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       AuthorizationPolicyKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: observed.Server.Namespace,
+				Name:      observed.Server.Name + "-clients",
+			},
+			Spec: policy.AuthorizationPolicySpec{
+				TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+					Group: k8s.PolicyAPIGroup,
+					Kind:  k8s.ServerKind,
+					Name:  gatewayapiv1alpha2.ObjectName(observed.Server.Name),
+				},
+				RequiredAuthenticationRefs: []gatewayapiv1alpha2.PolicyTargetReference{
+					{
+						Group: k8s.PolicyAPIGroup,
+						Kind:  MeshTLSAuthenticationKind,
+						Name:  gatewayapiv1alpha2.ObjectName(authn.Name),
+					},
+				},
+			},
+		}
+
+		generated = append(generated, GeneratedPolicy{Authentication: authn, Policy: authzPolicy})
+	}
+
+	return generated
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestGenerateServers(t *testing.T) {
+	resources := testResources()
+	resources.Pods.Items = append(resources.Pods.Items,
+		testMeshedPod("a", "web", 8080),
+		testMeshedPod("b", "web", 8080),
+		testMeshedPod("c", "web", 8080),
+	)
+	resources.Services.Items = append(resources.Services.Items,
+		testService("a", "web", 8080),
+		testService("b", "web", 8080),
+	)
+	// the Server of namespace b must not hide the uncovered port in namespace a
+	resources.Servers = append(resources.Servers, testServer("b", "web-8080", "web", 8080))
+
+	servers, err := GenerateServers(resources, "linkerd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var generated []string
+	for _, srv := range servers {
+		generated = append(generated, ObjectReference{Kind: ServerKind, Namespace: srv.Namespace, Name: srv.Name}.String()+":"+srv.Spec.Port.String())
+	}
+	assertStrings(t, "servers", []string{"a/Server/web-8080:8080"}, generated)
+}
@@ -0,0 +1,277 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sort"
+)
+
+const (
+	IdentityKind        = "Identity"
+	NetworkKind         = "Network"
+	UnauthenticatedKind = "Unauthenticated"
+)
+
+// Edge labels are named after the fields that reference the target object
+const (
+	PodSelectorEdge               = "podSelector"
+	ParentRefEdge                 = "parentRef"
+	TargetRefEdge                 = "targetRef"
+	ServerEdge                    = "server"
+	RequiredAuthenticationRefEdge = "requiredAuthenticationRef"
+	IdentitiesEdge                = "identities"
+	IdentityRefsEdge              = "identityRefs"
+	ServiceAccountsEdge           = "serviceAccounts"
+	NetworksEdge                  = "networks"
+	UnauthenticatedEdge           = "unauthenticated"
+)
+
+// GraphNode is a Kubernetes object or a client (identity, network) in the authorization graph,
+// missing nodes are referenced by other objects but don't exist
+type GraphNode struct {
+	ID string `json:"id"`
+	ObjectReference
+	Missing bool `json:"missing,omitempty"`
+}
+
+// GraphEdge is a reference from one node to another
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// Graph is the authorization graph: Pod → Server → HTTPRoute → policy → authentication → clients
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type graphBuilder struct {
+	resources *K8sResources
+	nodes     map[string]GraphNode
+	edges     map[GraphEdge]struct{}
+}
+
+// BuildGraph walks Servers, routes, policies and authentications and returns the sorted authorization graph
+func BuildGraph(resources *K8sResources) (*Graph, error) {
+	b := &graphBuilder{
+		resources: resources,
+		nodes:     map[string]GraphNode{},
+		edges:     map[GraphEdge]struct{}{},
+	}
+
+	for _, srv := range resources.Servers {
+		srvNode := b.node(ObjectReference{Kind: ServerKind, Namespace: srv.Namespace, Name: srv.Name}, false)
+
+		if srv.Spec.PodSelector != nil && resources.Pods != nil {
+			selector, err := metav1.LabelSelectorAsSelector(srv.Spec.PodSelector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create selector: %w", err)
+			}
+
+			for _, pod := range resources.Pods.Items {
+				if pod.Namespace != srv.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+				if _, ok := ServerContainerPort(srv, pod); !ok {
+					continue
+				}
+
+				podNode := b.node(ObjectReference{Kind: PodKind, Namespace: pod.Namespace, Name: pod.Name}, false)
+				b.edge(podNode, srvNode, PodSelectorEdge)
+			}
+		}
+
+		for _, route := range resources.HTTPRoutes {
+			if RouteAttachedToServer(route, srv) {
+				routeNode := b.node(ObjectReference{Kind: HTTPRouteKind, Namespace: route.Namespace, Name: route.Name}, false)
+				b.edge(srvNode, routeNode, ParentRefEdge)
+			}
+		}
+
+		rules, err := AccessRulesForServer(resources, srv)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			policyNode := b.node(rule.Policy, false)
+
+			switch {
+			case rule.Route != nil:
+				routeNode := b.node(ObjectReference{Kind: HTTPRouteKind, Namespace: rule.Route.Namespace, Name: rule.Route.Name}, false)
+				b.edge(routeNode, policyNode, TargetRefEdge)
+			case rule.Policy.Kind == ServerAuthorizationKind:
+				b.edge(srvNode, policyNode, ServerEdge)
+			default:
+				b.edge(srvNode, policyNode, TargetRefEdge)
+			}
+		}
+	}
+
+	for _, serverAuthorization := range resources.ServerAuthorizations {
+		b.addServerAuthorization(serverAuthorization)
+	}
+
+	for _, authzPolicy := range resources.AuthorizationPolicies {
+		b.addAuthorizationPolicy(authzPolicy)
+	}
+
+	return b.graph(), nil
+}
+
+func (b *graphBuilder) addServerAuthorization(serverAuthorization *saz.ServerAuthorization) {
+	sazNode := b.node(ObjectReference{Kind: ServerAuthorizationKind, Namespace: serverAuthorization.Namespace, Name: serverAuthorization.Name}, false)
+
+	client := serverAuthorization.Spec.Client
+	for _, network := range client.Networks {
+		b.edge(sazNode, b.node(networkReference(network.Cidr, network.Except), false), NetworksEdge)
+	}
+
+	if client.Unauthenticated {
+		b.edge(sazNode, b.node(unauthenticatedReference(), false), UnauthenticatedEdge)
+		return
+	}
+
+	if client.MeshTLS == nil {
+		return
+	}
+
+	if client.MeshTLS.UnauthenticatedTLS {
+		b.edge(sazNode, b.node(unauthenticatedReference(), false), UnauthenticatedEdge)
+	}
+
+	for _, identity := range client.MeshTLS.Identities {
+		b.edge(sazNode, b.node(ObjectReference{Kind: IdentityKind, Name: identity}, false), IdentitiesEdge)
+	}
+
+	for _, sa := range client.MeshTLS.ServiceAccounts {
+		namespace := sa.Namespace
+		if namespace == "" {
+			namespace = serverAuthorization.Namespace
+		}
+		b.edge(sazNode, b.node(ObjectReference{Kind: ServiceAccountKind, Namespace: namespace, Name: sa.Name}, false), ServiceAccountsEdge)
+	}
+}
+
+func (b *graphBuilder) addAuthorizationPolicy(authzPolicy *policy.AuthorizationPolicy) {
+	policyNode := b.node(ObjectReference{Kind: AuthorizationPolicyKind, Namespace: authzPolicy.Namespace, Name: authzPolicy.Name}, false)
+
+	if len(authzPolicy.Spec.RequiredAuthenticationRefs) == 0 {
+		b.edge(policyNode, b.node(unauthenticatedReference(), false), UnauthenticatedEdge)
+		return
+	}
+
+	for _, ref := range authzPolicy.Spec.RequiredAuthenticationRefs {
+		namespace := authzPolicy.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+		authnRef := ObjectReference{Kind: string(ref.Kind), Namespace: namespace, Name: string(ref.Name)}
+
+		switch string(ref.Kind) {
+		case MeshTLSAuthenticationKind:
+			authn := findMeshTLSAuthentication(b.resources, namespace, string(ref.Name))
+			authnNode := b.node(authnRef, authn == nil)
+			b.edge(policyNode, authnNode, RequiredAuthenticationRefEdge)
+			if authn == nil {
+				continue
+			}
+
+			for _, identity := range authn.Spec.Identities {
+				b.edge(authnNode, b.node(ObjectReference{Kind: IdentityKind, Name: identity}, false), IdentitiesEdge)
+			}
+
+			for _, identityRef := range authn.Spec.IdentityRefs {
+				identityNamespace := authn.Namespace
+				if identityRef.Namespace != nil {
+					identityNamespace = string(*identityRef.Namespace)
+				}
+
+				var target ObjectReference
+				switch string(identityRef.Kind) {
+				case ServiceAccountKind:
+					target = ObjectReference{Kind: ServiceAccountKind, Namespace: identityNamespace, Name: string(identityRef.Name)}
+				case NamespaceKind:
+					target = ObjectReference{Kind: NamespaceKind, Name: string(identityRef.Name)}
+				default:
+					continue
+				}
+				b.edge(authnNode, b.node(target, false), IdentityRefsEdge)
+			}
+
+		case NetworkAuthenticationKind:
+			authn := findNetworkAuthentication(b.resources, namespace, string(ref.Name))
+			authnNode := b.node(authnRef, authn == nil)
+			b.edge(policyNode, authnNode, RequiredAuthenticationRefEdge)
+			if authn == nil {
+				continue
+			}
+
+			for _, network := range authn.Spec.Networks {
+				b.edge(authnNode, b.node(networkReference(network.Cidr, network.Except), false), NetworksEdge)
+			}
+
+		default:
+			b.edge(policyNode, b.node(authnRef, false), RequiredAuthenticationRefEdge)
+		}
+	}
+}
+
+func (b *graphBuilder) node(ref ObjectReference, missing bool) GraphNode {
+	id := ref.String()
+	if node, ok := b.nodes[id]; ok {
+		return node
+	}
+
+	node := GraphNode{ID: id, ObjectReference: ref, Missing: missing}
+	b.nodes[id] = node
+	return node
+}
+
+func (b *graphBuilder) edge(from, to GraphNode, label string) {
+	b.edges[GraphEdge{From: from.ID, To: to.ID, Label: label}] = struct{}{}
+}
+
+func (b *graphBuilder) graph() *Graph {
+	graph := &Graph{
+		Nodes: make([]GraphNode, 0, len(b.nodes)),
+		Edges: make([]GraphEdge, 0, len(b.edges)),
+	}
+
+	for _, node := range b.nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for edge := range b.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	// stable order keeps the output diffable
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Label < b.Label
+	})
+
+	return graph
+}
+
+func networkReference(cidr string, except []string) ObjectReference {
+	return ObjectReference{Kind: NetworkKind, Name: Network{Cidr: cidr, Except: except}.String()}
+}
+
+func unauthenticatedReference() ObjectReference {
+	return ObjectReference{Kind: UnauthenticatedKind, Name: "*"}
+}
@@ -0,0 +1,222 @@
+package common
+
+import (
+	"fmt"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	"net"
+	"strings"
+)
+
+const (
+	DefaultTrustDomain = "cluster.local"
+
+	// DefaultInboundPolicyAnnotation overrides the cluster default inbound policy for a namespace or pod
+	DefaultInboundPolicyAnnotation = "config.linkerd.io/default-inbound-policy"
+
+	AllUnauthenticatedPolicy     = "all-unauthenticated"
+	AllAuthenticatedPolicy       = "all-authenticated"
+	ClusterUnauthenticatedPolicy = "cluster-unauthenticated"
+	ClusterAuthenticatedPolicy   = "cluster-authenticated"
+	DenyPolicy                   = "deny"
+	AuditPolicy                  = "audit"
+
+	// DefaultPolicyAnnotation on a namespace makes the injector set DefaultInboundPolicyAnnotation on its pods,
+	// so a default policy like deny can be rolled out namespace by namespace
+	DefaultPolicyAnnotation = "easyauth.linkerd.io/default-policy"
+)
+
+var defaultInboundPolicies = map[string]bool{
+	AllUnauthenticatedPolicy:     true,
+	AllAuthenticatedPolicy:       true,
+	ClusterUnauthenticatedPolicy: true,
+	ClusterAuthenticatedPolicy:   true,
+	DenyPolicy:                   true,
+	AuditPolicy:                  true,
+}
+
+// DefaultClusterNetworks are the networks that Linkerd treats as cluster ones by default
+var DefaultClusterNetworks = []string{"10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16"}
+
+// NamespaceDefaultPolicy returns the default inbound policy from DefaultPolicyAnnotation of the namespace,
+// it is empty if the namespace is nil or not annotated
+func NamespaceDefaultPolicy(namespace *v1.Namespace) (string, error) {
+	if namespace == nil {
+		return "", nil
+	}
+
+	policy := namespace.Annotations[DefaultPolicyAnnotation]
+	if policy != "" && !defaultInboundPolicies[policy] {
+		return "", fmt.Errorf("namespace %s has unsupported %s: %s", namespace.Name, DefaultPolicyAnnotation, policy)
+	}
+
+	return policy, nil
+}
+
+// Client is a caller identified by its namespace, service account and mesh identity
+type Client struct {
+	Namespace      string   `json:"namespace"`
+	ServiceAccount string   `json:"serviceAccount"`
+	Identity       string   `json:"identity,omitempty"`
+	IPs            []string `json:"ips,omitempty"`
+}
+
+// ServiceAccountIdentity returns the mesh TLS identity that Linkerd issues for a service account
+func ServiceAccountIdentity(serviceAccount, namespace, controlPlaneNamespace, trustDomain string) string {
+	return fmt.Sprintf("%s.%s.serviceaccount.identity.%s.%s", serviceAccount, namespace, controlPlaneNamespace, trustDomain)
+}
+
+// IdentityMatches matches an identity against a MeshTLSAuthentication identity that could be "*" or
+// a wildcard like *.ns.serviceaccount.identity.linkerd.cluster.local
+func IdentityMatches(pattern, identity string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(identity, pattern[1:])
+	}
+
+	return pattern == identity
+}
+
+// ClientForPods returns the client that calls from pods, pods are expected to share the service account.
+// Unmeshed pods have no identity
+func ClientForPods(pods []v1.Pod, controlPlaneNamespace, trustDomain string) Client {
+	var client Client
+
+	for i, pod := range pods {
+		if i == 0 {
+			client.Namespace = pod.Namespace
+			client.ServiceAccount = pod.Spec.ServiceAccountName
+			if client.ServiceAccount == "" {
+				client.ServiceAccount = "default"
+			}
+
+			if k8s.IsMeshed(&pod, controlPlaneNamespace) {
+				client.Identity = ServiceAccountIdentity(client.ServiceAccount, client.Namespace, controlPlaneNamespace, trustDomain)
+			}
+		}
+
+		for _, ip := range pod.Status.PodIPs {
+			client.IPs = append(client.IPs, ip.IP)
+		}
+	}
+
+	return client
+}
+
+// Admits checks the mesh TLS part of clients: identities, service accounts and namespaces,
+// it returns the matched entries, one for each required authentication
+func (c Clients) Admits(client Client) (string, bool) {
+	if !c.RequiresMeshTLS() || c.Unauthenticated {
+		return "unauthenticated", true
+	}
+
+	if client.Identity == "" {
+		return "", false
+	}
+
+	var admittedBy []string
+	for _, clients := range c.Groups() {
+		if !clients.hasMeshTLS() {
+			continue
+		}
+
+		matched, ok := clients.admitsMeshTLS(client)
+		if !ok {
+			return "", false
+		}
+		admittedBy = append(admittedBy, matched)
+	}
+
+	return strings.Join(admittedBy, " and "), true
+}
+
+func (c Clients) admitsMeshTLS(client Client) (string, bool) {
+	for _, identity := range c.Identities {
+		if IdentityMatches(identity, client.Identity) {
+			return "identity " + identity, true
+		}
+	}
+
+	for _, sa := range c.ServiceAccounts {
+		if sa == client.Namespace+"/"+client.ServiceAccount {
+			return "serviceaccount " + sa, true
+		}
+	}
+
+	for _, ns := range c.Namespaces {
+		if ns == client.Namespace {
+			return "namespace " + ns, true
+		}
+	}
+
+	return "", false
+}
+
+// AdmitsNetwork checks client IPs against networks of each required authentication, if client IPs
+// are unknown (eg. manifests) the client is assumed to be in any network
+func (c Clients) AdmitsNetwork(client Client) (string, bool) {
+	var admittedBy []string
+	for _, clients := range c.Groups() {
+		if len(clients.Networks) == 0 {
+			continue
+		}
+
+		matched, ok := clients.admitsNetwork(client)
+		if !ok {
+			return "", false
+		}
+		admittedBy = append(admittedBy, matched)
+	}
+
+	return strings.Join(admittedBy, " and "), true
+}
+
+func (c Clients) admitsNetwork(client Client) (string, bool) {
+	if len(client.IPs) == 0 {
+		return "network " + c.Networks[0].String() + " (client IP is unknown)", true
+	}
+
+	for _, network := range c.Networks {
+		for _, ip := range client.IPs {
+			if network.Contains(ip) {
+				return "network " + network.String(), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Contains is true if the IP belongs to the network and not to its exceptions
+func (n Network) Contains(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	if !cidrContains(n.Cidr, addr) {
+		return false
+	}
+
+	for _, except := range n.Except {
+		if cidrContains(except, addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func cidrContains(cidr string, addr net.IP) bool {
+	if !strings.Contains(cidr, "/") {
+		return net.ParseIP(cidr).Equal(addr)
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	return network.Contains(addr)
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"context"
+	"errors"
+	l5dcrdinformer "github.com/linkerd/linkerd2/controller/gen/client/informers/externalversions"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// InformerSource serves resources from informer caches that are kept up to date,
+// it is used by in-cluster components that re-evaluate resources on every change
+type InformerSource struct {
+	k8sInformers informers.SharedInformerFactory
+	l5dInformers l5dcrdinformer.SharedInformerFactory
+}
+
+// NewInformerSource starts informers for all resources that easyauth checks need and waits for their caches
+func NewInformerSource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, resync time.Duration) (*InformerSource, error) {
+	l5dInformers, err := initServerAPI(ctx, k8sAPI.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &InformerSource{
+		k8sInformers: informers.NewSharedInformerFactory(k8sAPI.Interface, resync),
+		l5dInformers: l5dInformers,
+	}
+
+	// informers should be requested before the factory is started
+	synced := []cache.InformerSynced{}
+	for _, informer := range s.informers() {
+		synced = append(synced, informer.HasSynced)
+	}
+
+	s.k8sInformers.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		return nil, errors.New("failed to sync informer caches")
+	}
+
+	return s, nil
+}
+
+// OnChange calls the handler on every add, update and delete of watched resources
+func (s *InformerSource) OnChange(handler func()) {
+	handlers := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handler() },
+		UpdateFunc: func(oldObj, newObj interface{}) { handler() },
+		DeleteFunc: func(obj interface{}) { handler() },
+	}
+
+	for _, informer := range s.informers() {
+		informer.AddEventHandler(handlers)
+	}
+}
+
+func (s *InformerSource) informers() []cache.SharedIndexInformer {
+	return []cache.SharedIndexInformer{
+		s.k8sInformers.Core().V1().Namespaces().Informer(),
+		s.k8sInformers.Core().V1().Pods().Informer(),
+		s.k8sInformers.Core().V1().Services().Informer(),
+		s.k8sInformers.Apps().V1().Deployments().Informer(),
+		s.k8sInformers.Apps().V1().StatefulSets().Informer(),
+		s.k8sInformers.Apps().V1().DaemonSets().Informer(),
+		s.l5dInformers.Server().V1beta1().Servers().Informer(),
+		s.l5dInformers.Serverauthorization().V1beta1().ServerAuthorizations().Informer(),
+		s.l5dInformers.Policy().V1alpha1().AuthorizationPolicies().Informer(),
+		s.l5dInformers.Policy().V1alpha1().HTTPRoutes().Informer(),
+		s.l5dInformers.Policy().V1alpha1().MeshTLSAuthentications().Informer(),
+		s.l5dInformers.Policy().V1alpha1().NetworkAuthentications().Informer(),
+	}
+}
+
+func (s *InformerSource) Fetch(ctx context.Context, namespace string) (*K8sResources, error) {
+	everything := labels.Everything()
+
+	resources := &K8sResources{
+		Namespaces:   &v1.NamespaceList{},
+		Pods:         &v1.PodList{},
+		Services:     &v1.ServiceList{},
+		Deployments:  &appsv1.DeploymentList{},
+		StatefulSets: &appsv1.StatefulSetList{},
+		DaemonSets:   &appsv1.DaemonSetList{},
+	}
+
+	namespaces, err := s.k8sInformers.Core().V1().Namespaces().Lister().List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range namespaces {
+		if namespace == "" || obj.Name == namespace {
+			resources.Namespaces.Items = append(resources.Namespaces.Items, *obj)
+		}
+	}
+
+	pods, err := s.k8sInformers.Core().V1().Pods().Lister().Pods(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range pods {
+		resources.Pods.Items = append(resources.Pods.Items, *obj)
+	}
+
+	services, err := s.k8sInformers.Core().V1().Services().Lister().Services(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range services {
+		resources.Services.Items = append(resources.Services.Items, *obj)
+	}
+
+	deployments, err := s.k8sInformers.Apps().V1().Deployments().Lister().Deployments(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range deployments {
+		resources.Deployments.Items = append(resources.Deployments.Items, *obj)
+	}
+
+	statefulSets, err := s.k8sInformers.Apps().V1().StatefulSets().Lister().StatefulSets(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range statefulSets {
+		resources.StatefulSets.Items = append(resources.StatefulSets.Items, *obj)
+	}
+
+	daemonSets, err := s.k8sInformers.Apps().V1().DaemonSets().Lister().DaemonSets(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range daemonSets {
+		resources.DaemonSets.Items = append(resources.DaemonSets.Items, *obj)
+	}
+
+	resources.Servers, err = s.l5dInformers.Server().V1beta1().Servers().Lister().Servers(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.ServerAuthorizations, err = s.l5dInformers.Serverauthorization().V1beta1().ServerAuthorizations().Lister().ServerAuthorizations(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.AuthorizationPolicies, err = s.l5dInformers.Policy().V1alpha1().AuthorizationPolicies().Lister().AuthorizationPolicies(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.HTTPRoutes, err = s.l5dInformers.Policy().V1alpha1().HTTPRoutes().Lister().HTTPRoutes(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.MeshTLSAuthentications, err = s.l5dInformers.Policy().V1alpha1().MeshTLSAuthentications().Lister().MeshTLSAuthentications(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	resources.NetworkAuthentications, err = s.l5dInformers.Policy().V1alpha1().NetworkAuthentications().Lister().NetworkAuthentications(namespace).List(everything)
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
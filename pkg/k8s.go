@@ -1,89 +1,113 @@
 package common
 
 import (
-	"context"
 	"fmt"
-	policies "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
 	"github.com/linkerd/linkerd2/pkg/k8s"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"os"
+	"sort"
+	"strings"
 
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
 	serverv1beta1 "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
-	serverauthorizationv1beta1 "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 )
 
+type namespacedSelector struct {
+	namespace string
+	selector  labels.Selector
+}
+
+// authCandidate is a policy that applies to a Server, directly, through the namespace or through an HTTPRoute
 type authCandidate struct {
-	Server        serverv1beta1.Server
+	Server        *serverv1beta1.Server
 	Authorization k8s.Authorization
+
+	serverAuthorization *saz.ServerAuthorization
+	policy              *policy.AuthorizationPolicy
+	route               *policy.HTTPRoute
 }
 
-func AuthorizationsForResource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, policies []*policies.AuthorizationPolicy, httpRoutes []*policies.HTTPRoute, serverAuthorizations []*serverauthorizationv1beta1.ServerAuthorization, servers []*serverv1beta1.Server, namespace string, resource string) ([]k8s.Authorization, error) {
-	pods, err := k8s.GetPodsFor(ctx, k8sAPI, namespace, resource)
-	if err != nil {
-		return nil, err
+// rule resolves clients that the policy of the candidate admits
+func (c authCandidate) rule(resources *K8sResources) AccessRule {
+	if c.serverAuthorization != nil {
+		return serverAuthorizationRule(c.serverAuthorization, c.Server)
 	}
+	return authorizationPolicyRule(resources, c.policy, c.Server, c.route)
+}
 
-	results := make([]k8s.Authorization, 0)
-
+// authorizationCandidates cross-references ServerAuthorizations, AuthorizationPolicies and HTTPRoutes
+// with the Servers they apply to, it is shared by inbound and outbound lookups
+func authorizationCandidates(resources *K8sResources, servers []*serverv1beta1.Server) ([]authCandidate, error) {
 	var candidates []authCandidate
 
-	for _, saz := range serverAuthorizations {
+	for _, serverAuthorization := range resources.ServerAuthorizations {
 		for _, srv := range servers {
-			selector, err := metav1.LabelSelectorAsSelector(saz.Spec.Server.Selector)
+			applies, err := ServerAuthorizationAppliesToServer(serverAuthorization, srv)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to create selector: %s\n", err)
-				os.Exit(1)
+				return nil, err
 			}
 
-			if selector.Matches(labels.Set(srv.GetLabels())) || saz.Spec.Server.Name == srv.GetName() {
-				authorization := k8s.Authorization{
-					Server:              srv.GetName(),
-					ServerAuthorization: saz.GetName(),
-					AuthorizationPolicy: "",
-				}
-				candidates = append(candidates, authCandidate{Server: *srv, Authorization: authorization})
+			if applies {
+				candidates = append(candidates, authCandidate{
+					Server:              srv,
+					Authorization:       k8s.Authorization{Server: srv.GetName(), ServerAuthorization: serverAuthorization.GetName()},
+					serverAuthorization: serverAuthorization,
+				})
 			}
 		}
 	}
 
-	for _, policy := range policies {
-		target := policy.Spec.TargetRef
-		if target.Kind == "Namespace" || (target.Kind == k8s.ServerKind && target.Group == k8s.PolicyAPIGroup) {
-			for _, srv := range servers {
-				if target.Kind == "Namespace" || (string(target.Name) == srv.GetName()) {
-					authorization := k8s.Authorization{
-						Server:              srv.GetName(),
-						ServerAuthorization: "",
-						AuthorizationPolicy: policy.GetName(),
-					}
-					candidates = append(candidates, authCandidate{Server: *srv, Authorization: authorization})
-				}
+	for _, authzPolicy := range resources.AuthorizationPolicies {
+		for _, srv := range servers {
+			if PolicyTargetsServer(authzPolicy, srv) {
+				candidates = append(candidates, authCandidate{
+					Server:        srv,
+					Authorization: k8s.Authorization{Server: srv.GetName(), AuthorizationPolicy: authzPolicy.GetName()},
+					policy:        authzPolicy,
+				})
 			}
 		}
 
-		if target.Kind == k8s.HTTPRouteKind {
-			for _, httpRoute := range httpRoutes {
-				for _, targetRef := range httpRoute.Spec.ParentRefs {
-					if *targetRef.Kind == k8s.ServerKind {
-						for _, srv := range servers {
-							if *targetRef.Kind == k8s.ServerKind && string(targetRef.Name) == srv.GetName() && string(policy.Spec.TargetRef.Name) == httpRoute.GetName() {
-								authorization := k8s.Authorization{
-									Route:               httpRoute.Name,
-									Server:              srv.GetName(),
-									ServerAuthorization: "",
-									AuthorizationPolicy: policy.GetName(),
-								}
-								candidates = append(candidates, authCandidate{Server: *srv, Authorization: authorization})
-							}
-						}
-					}
+		for _, route := range PolicyRoutes(resources, authzPolicy) {
+			for _, srv := range servers {
+				if RouteAttachedToServer(route, srv) {
+					candidates = append(candidates, authCandidate{
+						Server:        srv,
+						Authorization: k8s.Authorization{Route: route.GetName(), Server: srv.GetName(), AuthorizationPolicy: authzPolicy.GetName()},
+						policy:        authzPolicy,
+						route:         route,
+					})
 				}
 			}
 		}
 	}
 
+	return candidates, nil
+}
+
+func AuthorizationsForResource(resources *K8sResources, namespace string, resource string) ([]k8s.Authorization, error) {
+	pods, err := PodsForResource(resources, namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]k8s.Authorization, 0)
+
+	// the snapshot can contain several namespaces, so Servers of other namespaces are skipped
+	var servers []*serverv1beta1.Server
+	for _, srv := range resources.Servers {
+		if namespace == "" || srv.Namespace == namespace {
+			servers = append(servers, srv)
+		}
+	}
+
+	candidates, err := authorizationCandidates(resources, servers)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, candidate := range candidates {
 		server := candidate.Server
 		if server.Spec.PodSelector == nil {
@@ -92,8 +116,7 @@ func AuthorizationsForResource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, p
 
 		selector, err := metav1.LabelSelectorAsSelector(server.Spec.PodSelector)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create selector: %s\n", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("failed to create selector: %w", err)
 		}
 
 		var selectedPods []corev1.Pod
@@ -103,7 +126,7 @@ func AuthorizationsForResource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, p
 			}
 		}
 
-		if serverIncludesPod(server, selectedPods) {
+		if serverIncludesPod(*server, selectedPods) {
 			results = append(results, candidate.Authorization)
 		}
 	}
@@ -111,6 +134,111 @@ func AuthorizationsForResource(ctx context.Context, k8sAPI *k8s.KubernetesAPI, p
 	return results, nil
 }
 
+// PodsForResource returns pods of a resource like deploy/web from the prefetched resources
+func PodsForResource(resources *K8sResources, namespace string, resource string) ([]corev1.Pod, error) {
+	parts := strings.SplitN(resource, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("resource %q should be in form of <type>/<name>", resource)
+	}
+
+	kind, err := k8s.CanonicalResourceNameFromFriendlyName(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	name := parts[1]
+
+	inNamespace := func(meta metav1.Object) bool {
+		return namespace == "" || meta.GetNamespace() == namespace
+	}
+
+	var selectors []namespacedSelector
+	addSelector := func(meta metav1.Object, labelSelector *metav1.LabelSelector) error {
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return fmt.Errorf("failed to create selector: %w", err)
+		}
+		selectors = append(selectors, namespacedSelector{namespace: meta.GetNamespace(), selector: selector})
+		return nil
+	}
+
+	pods := []corev1.Pod{}
+
+	switch kind {
+	case k8s.Pod:
+		for _, pod := range resources.Pods.Items {
+			if pod.Name == name && inNamespace(&pod) {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			return nil, fmt.Errorf("%s %s not found", kind, name)
+		}
+		return pods, nil
+
+	case k8s.Namespace:
+		for _, pod := range resources.Pods.Items {
+			if pod.Namespace == name {
+				pods = append(pods, pod)
+			}
+		}
+		return pods, nil
+
+	case k8s.Deployment:
+		for _, obj := range resources.Deployments.Items {
+			if obj.Name == name && inNamespace(&obj) {
+				if err := addSelector(&obj, obj.Spec.Selector); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	case k8s.StatefulSet:
+		for _, obj := range resources.StatefulSets.Items {
+			if obj.Name == name && inNamespace(&obj) {
+				if err := addSelector(&obj, obj.Spec.Selector); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	case k8s.DaemonSet:
+		for _, obj := range resources.DaemonSets.Items {
+			if obj.Name == name && inNamespace(&obj) {
+				if err := addSelector(&obj, obj.Spec.Selector); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	case k8s.Service:
+		for _, obj := range resources.Services.Items {
+			if obj.Name == name && inNamespace(&obj) && len(obj.Spec.Selector) > 0 {
+				if err := addSelector(&obj, &metav1.LabelSelector{MatchLabels: obj.Spec.Selector}); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported resource type %s", kind)
+	}
+
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("%s %s not found", kind, name)
+	}
+
+	for _, pod := range resources.Pods.Items {
+		for _, s := range selectors {
+			if pod.Namespace == s.namespace && s.selector.Matches(labels.Set(pod.Labels)) {
+				pods = append(pods, pod)
+				break
+			}
+		}
+	}
+
+	return pods, nil
+}
+
 func serverIncludesPod(server serverv1beta1.Server, serverPods []corev1.Pod) bool {
 	for _, pod := range serverPods {
 		for _, container := range pod.Spec.Containers {
@@ -123,3 +251,59 @@ func serverIncludesPod(server serverv1beta1.Server, serverPods []corev1.Pod) boo
 	}
 	return false
 }
+
+// WorkloadsForSelector returns deployments, statefulsets, daemonsets and standalone pods whose
+// labels or pod template labels match the selector, sorted by namespace, kind and name
+func WorkloadsForSelector(resources *K8sResources, namespace string, selector labels.Selector) []ObjectReference {
+	var workloads []ObjectReference
+
+	add := func(kind string, meta metav1.Object, templateLabels map[string]string) {
+		if namespace != "" && meta.GetNamespace() != namespace {
+			return
+		}
+		if !selector.Matches(labels.Set(meta.GetLabels())) && !selector.Matches(labels.Set(templateLabels)) {
+			return
+		}
+		workloads = append(workloads, ObjectReference{Kind: kind, Namespace: meta.GetNamespace(), Name: meta.GetName()})
+	}
+
+	if resources.Deployments != nil {
+		for i, obj := range resources.Deployments.Items {
+			add(DeploymentKind, &resources.Deployments.Items[i], obj.Spec.Template.Labels)
+		}
+	}
+
+	if resources.StatefulSets != nil {
+		for i, obj := range resources.StatefulSets.Items {
+			add(StatefulSetKind, &resources.StatefulSets.Items[i], obj.Spec.Template.Labels)
+		}
+	}
+
+	if resources.DaemonSets != nil {
+		for i, obj := range resources.DaemonSets.Items {
+			add(DaemonSetKind, &resources.DaemonSets.Items[i], obj.Spec.Template.Labels)
+		}
+	}
+
+	if resources.Pods != nil {
+		for i, obj := range resources.Pods.Items {
+			// pods of controllers are covered by their workloads
+			if len(obj.OwnerReferences) == 0 {
+				add(PodKind, &resources.Pods.Items[i], obj.Labels)
+			}
+		}
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	return workloads
+}
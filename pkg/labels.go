@@ -1,21 +1,109 @@
 package common
 
 import (
+	"github.com/linkerd/linkerd2/pkg/k8s"
 	v1 "k8s.io/api/core/v1"
 	"strconv"
 )
 
+// Marker keys are the contract between the injector, the CLI and the chart:
+// the injector writes EasyAuthEnabledKey both as a label, so Servers can select marked pods, and as an annotation
 const (
-	EasyAuthAnnotation = "linkerd-io/easyauth-enabled"
+	EasyAuthEnabledKey   = "linkerd.io/easyauth-enabled"
+	EasyAuthEnabledValue = "true"
+
+	// EasyAuthInjectAnnotation with "disabled" value on a pod or a namespace stops the injector from marking pods
+	EasyAuthInjectAnnotation = "easyauth.linkerd.io/inject"
+	EasyAuthInjectDisabled   = "disabled"
 )
 
+// LegacyEasyAuthKeys were written or selected by earlier versions, they are still accepted as labels or annotations
+var LegacyEasyAuthKeys = []string{
+	"linkerd-io/easyauth-enabled",
+	"linkerd.io/easyauth",
+}
+
+// IsEasyAuthEnabled tells if the pod is marked by the current or a legacy key
 func IsEasyAuthEnabled(pod *v1.Pod) bool {
-	valStr := pod.GetAnnotations()[EasyAuthAnnotation]
-	if valStr != "" {
-		valBool, err := strconv.ParseBool(valStr)
-		if err == nil && valBool {
+	if hasMarker(pod, EasyAuthEnabledKey) {
+		return true
+	}
+
+	for _, key := range LegacyEasyAuthKeys {
+		if hasMarker(pod, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasOnlyLegacyEasyAuthKey tells if the pod is marked by a legacy key but has no current label, Servers don't select such pods
+func HasOnlyLegacyEasyAuthKey(pod *v1.Pod) bool {
+	if isTrue(pod.GetLabels()[EasyAuthEnabledKey]) {
+		return false
+	}
+
+	for _, key := range LegacyEasyAuthKeys {
+		if hasMarker(pod, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasMarker(pod *v1.Pod, key string) bool {
+	return isTrue(pod.GetLabels()[key]) || isTrue(pod.GetAnnotations()[key])
+}
+
+func isTrue(value string) bool {
+	valBool, err := strconv.ParseBool(value)
+	return err == nil && valBool
+}
+
+// IsInjectedByLinkerd tells if the pod already has the proxy or the Linkerd proxy injector is going to add it,
+// pod annotations take precedence over namespace ones, namespace may be nil if it is unknown
+func IsInjectedByLinkerd(pod *v1.Pod, namespace *v1.Namespace) bool {
+	if _, ok := pod.Labels[k8s.ControllerNSLabel]; ok {
+		return true
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name == k8s.ProxyContainerName {
 			return true
 		}
 	}
+
+	// Linkerd never injects pods in the host network
+	if pod.Spec.HostNetwork {
+		return false
+	}
+
+	switch pod.Annotations[k8s.ProxyInjectAnnotation] {
+	case k8s.ProxyInjectEnabled, k8s.ProxyInjectIngress:
+		return true
+	case k8s.ProxyInjectDisabled:
+		return false
+	}
+
+	if namespace == nil {
+		return false
+	}
+
+	switch namespace.Annotations[k8s.ProxyInjectAnnotation] {
+	case k8s.ProxyInjectEnabled, k8s.ProxyInjectIngress:
+		return true
+	}
+
 	return false
 }
+
+// IsEasyAuthInjectDisabled tells if the pod or its namespace opted out of the injector
+func IsEasyAuthInjectDisabled(pod *v1.Pod, namespace *v1.Namespace) bool {
+	if pod.Annotations[EasyAuthInjectAnnotation] == EasyAuthInjectDisabled {
+		return true
+	}
+
+	return namespace != nil && namespace.Annotations[EasyAuthInjectAnnotation] == EasyAuthInjectDisabled
+}
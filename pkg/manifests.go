@@ -0,0 +1,311 @@
+package common
+
+import (
+	"bufio"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"io"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	// StdinManifest is the file name that means "read manifests from stdin"
+	StdinManifest = "-"
+
+	policyAPIGroupPrefix = k8s.PolicyAPIGroup + "/"
+)
+
+// manifestLoader collects resources from Kubernetes manifests without any API server
+type manifestLoader struct {
+	namespace             string
+	controlPlaneNamespace string
+	namespaces            map[string]*v1.Namespace
+	pods                  []v1.Pod
+	resources             *K8sResources
+}
+
+// LoadManifestFiles reads YAML or JSON manifests from files, directories (recursively) or stdin ("-").
+// Objects without namespace are placed to the given namespace
+func LoadManifestFiles(paths []string, namespace, controlPlaneNamespace string) (*K8sResources, error) {
+	var readers []io.Reader
+
+	for _, path := range paths {
+		if path == StdinManifest {
+			readers = append(readers, os.Stdin)
+			continue
+		}
+
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			// explicitly provided files are read whatever their extension is
+			if file != path {
+				switch strings.ToLower(filepath.Ext(file)) {
+				case ".yaml", ".yml", ".json":
+				default:
+					return nil
+				}
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			readers = append(readers, f)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	defer func() {
+		for _, r := range readers {
+			if f, ok := r.(*os.File); ok && f != os.Stdin {
+				f.Close()
+			}
+		}
+	}()
+
+	return LoadManifests(namespace, controlPlaneNamespace, readers...)
+}
+
+// LoadManifests reads multi-document YAML or JSON manifests and collects resources used by easyauth checks.
+// Pods are synthesized from Deployments, StatefulSets and DaemonSets templates, and they are treated as meshed
+// if linkerd.io/inject annotation is enabled for the template or its namespace
+func LoadManifests(namespace, controlPlaneNamespace string, readers ...io.Reader) (*K8sResources, error) {
+	if namespace == "" {
+		namespace = v1.NamespaceDefault
+	}
+
+	loader := &manifestLoader{
+		namespace:             namespace,
+		controlPlaneNamespace: controlPlaneNamespace,
+		namespaces:            map[string]*v1.Namespace{},
+		resources: &K8sResources{
+			Namespaces:   &v1.NamespaceList{},
+			Pods:         &v1.PodList{},
+			Services:     &v1.ServiceList{},
+			Deployments:  &appsv1.DeploymentList{},
+			StatefulSets: &appsv1.StatefulSetList{},
+			DaemonSets:   &appsv1.DaemonSetList{},
+		},
+	}
+
+	for _, r := range readers {
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if err := loader.load(doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, pod := range loader.pods {
+		pod := pod
+		loader.markMeshed(&pod)
+		loader.resources.Pods.Items = append(loader.resources.Pods.Items, pod)
+	}
+
+	return loader.resources, nil
+}
+
+func (l *manifestLoader) load(doc []byte) error {
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+		return err
+	}
+
+	if typeMeta.Kind == "" {
+		// empty document or comments only
+		return nil
+	}
+
+	isPolicy := strings.HasPrefix(typeMeta.APIVersion, policyAPIGroupPrefix)
+
+	switch {
+	case typeMeta.Kind == "List":
+		var list v1.List
+		if err := yaml.Unmarshal(doc, &list); err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if err := l.load(item.Raw); err != nil {
+				return err
+			}
+		}
+
+	case typeMeta.Kind == "Namespace" && typeMeta.APIVersion == "v1":
+		var obj v1.Namespace
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.namespaces[obj.GetName()] = &obj
+		l.resources.Namespaces.Items = append(l.resources.Namespaces.Items, obj)
+
+	case typeMeta.Kind == "Pod" && typeMeta.APIVersion == "v1":
+		var obj v1.Pod
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.pods = append(l.pods, obj)
+
+	case typeMeta.Kind == "Service" && typeMeta.APIVersion == "v1":
+		var obj v1.Service
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.Services.Items = append(l.resources.Services.Items, obj)
+
+	case typeMeta.Kind == "Deployment" && typeMeta.APIVersion == "apps/v1":
+		var obj appsv1.Deployment
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.Deployments.Items = append(l.resources.Deployments.Items, obj)
+		l.pods = append(l.pods, podFromTemplate(typeMeta, obj.ObjectMeta, obj.Spec.Template))
+
+	case typeMeta.Kind == "StatefulSet" && typeMeta.APIVersion == "apps/v1":
+		var obj appsv1.StatefulSet
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.StatefulSets.Items = append(l.resources.StatefulSets.Items, obj)
+		l.pods = append(l.pods, podFromTemplate(typeMeta, obj.ObjectMeta, obj.Spec.Template))
+
+	case typeMeta.Kind == "DaemonSet" && typeMeta.APIVersion == "apps/v1":
+		var obj appsv1.DaemonSet
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.DaemonSets.Items = append(l.resources.DaemonSets.Items, obj)
+		l.pods = append(l.pods, podFromTemplate(typeMeta, obj.ObjectMeta, obj.Spec.Template))
+
+	case typeMeta.Kind == ServerKind && isPolicy:
+		var obj server.Server
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.Servers = append(l.resources.Servers, &obj)
+
+	case typeMeta.Kind == ServerAuthorizationKind && isPolicy:
+		var obj saz.ServerAuthorization
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.ServerAuthorizations = append(l.resources.ServerAuthorizations, &obj)
+
+	case typeMeta.Kind == AuthorizationPolicyKind && isPolicy:
+		var obj policy.AuthorizationPolicy
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.AuthorizationPolicies = append(l.resources.AuthorizationPolicies, &obj)
+
+	case typeMeta.Kind == HTTPRouteKind && isPolicy:
+		var obj policy.HTTPRoute
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.HTTPRoutes = append(l.resources.HTTPRoutes, &obj)
+
+	case typeMeta.Kind == MeshTLSAuthenticationKind && isPolicy:
+		var obj policy.MeshTLSAuthentication
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.MeshTLSAuthentications = append(l.resources.MeshTLSAuthentications, &obj)
+
+	case typeMeta.Kind == NetworkAuthenticationKind && isPolicy:
+		var obj policy.NetworkAuthentication
+		if err := l.decode(doc, &obj, &obj.ObjectMeta); err != nil {
+			return err
+		}
+		l.resources.NetworkAuthentications = append(l.resources.NetworkAuthentications, &obj)
+	}
+
+	return nil
+}
+
+func (l *manifestLoader) decode(doc []byte, obj interface{}, meta *metav1.ObjectMeta) error {
+	if err := yaml.Unmarshal(doc, obj); err != nil {
+		return err
+	}
+
+	if meta.Namespace == "" {
+		if _, ok := obj.(*v1.Namespace); !ok {
+			meta.Namespace = l.namespace
+		}
+	}
+	return nil
+}
+
+// markMeshed adds the control plane label to pods that would be injected by Linkerd, so they are
+// handled by checks the same way as pods running in the cluster
+func (l *manifestLoader) markMeshed(pod *v1.Pod) {
+	if _, ok := pod.Labels[k8s.ControllerNSLabel]; ok {
+		return
+	}
+
+	inject := pod.Annotations[k8s.ProxyInjectAnnotation]
+	if inject == "" {
+		if ns, ok := l.namespaces[pod.Namespace]; ok {
+			inject = ns.Annotations[k8s.ProxyInjectAnnotation]
+		}
+	}
+
+	if inject == k8s.ProxyInjectEnabled || inject == k8s.ProxyInjectIngress {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[k8s.ControllerNSLabel] = l.controlPlaneNamespace
+	}
+}
+
+func podFromTemplate(owner metav1.TypeMeta, ownerMeta metav1.ObjectMeta, template v1.PodTemplateSpec) v1.Pod {
+	podLabels := map[string]string{}
+	for k, v := range template.Labels {
+		podLabels[k] = v
+	}
+
+	return v1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: PodKind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ownerMeta.Name,
+			Namespace:   ownerMeta.Namespace,
+			Labels:      podLabels,
+			Annotations: template.Annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: owner.APIVersion,
+					Kind:       owner.Kind,
+					Name:       ownerMeta.Name,
+				},
+			},
+		},
+		Spec: template.Spec,
+	}
+}
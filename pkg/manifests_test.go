@@ -0,0 +1,284 @@
+package common
+
+import (
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// describeManifestPods returns "namespace/name owner meshed" lines that are compared by tests
+func describeManifestPods(resources *K8sResources) []string {
+	lines := []string{}
+	for _, pod := range resources.Pods.Items {
+		line := pod.Namespace + "/" + pod.Name
+		for _, owner := range pod.OwnerReferences {
+			line += " owner=" + owner.Kind + "/" + owner.Name
+		}
+		if pod.Labels[k8s.ControllerNSLabel] == "linkerd" {
+			line += " meshed"
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestLoadManifests(t *testing.T) {
+	testCases := []struct {
+		name      string
+		manifests string
+		namespace string
+		pods      []string
+		objects   []string
+		err       bool
+	}{
+		{
+			name: "pods from workload templates",
+			manifests: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: a
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+      annotations:
+        linkerd.io/inject: enabled
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: a
+spec:
+  template:
+    metadata:
+      labels:
+        app: db
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+  namespace: a
+spec:
+  template:
+    metadata:
+      annotations:
+        linkerd.io/inject: ingress
+`,
+			pods: []string{
+				"a/agent owner=DaemonSet/agent meshed",
+				"a/db owner=StatefulSet/db",
+				"a/web owner=Deployment/web meshed",
+			},
+		},
+		{
+			name: "namespace annotation is inherited and overridden by the template",
+			manifests: `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: a
+  annotations:
+    linkerd.io/inject: enabled
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: a
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: opt-out
+  namespace: a
+  annotations:
+    linkerd.io/inject: disabled
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: b
+`,
+			pods:    []string{"a/opt-out", "a/web meshed", "b/web"},
+			objects: []string{"Namespace/a"},
+		},
+		{
+			name:      "objects without namespace are placed to the given namespace",
+			namespace: "app",
+			manifests: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  labels:
+    linkerd.io/control-plane-ns: linkerd
+---
+apiVersion: policy.linkerd.io/v1beta1
+kind: Server
+metadata:
+  name: web
+`,
+			pods:    []string{"app/web meshed"},
+			objects: []string{"app/Server/web"},
+		},
+		{
+			name: "lists and JSON documents",
+			manifests: `
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "namespace": "a"}}
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: policy.linkerd.io/v1alpha1
+  kind: AuthorizationPolicy
+  metadata:
+    name: web
+    namespace: a
+- apiVersion: policy.linkerd.io/v1alpha1
+  kind: MeshTLSAuthentication
+  metadata:
+    name: clients
+    namespace: a
+- apiVersion: policy.linkerd.io/v1alpha1
+  kind: NetworkAuthentication
+  metadata:
+    name: cluster
+    namespace: a
+- apiVersion: policy.linkerd.io/v1beta1
+  kind: HTTPRoute
+  metadata:
+    name: web
+    namespace: a
+- apiVersion: policy.linkerd.io/v1beta1
+  kind: ServerAuthorization
+  metadata:
+    name: web
+    namespace: a
+`,
+			pods: []string{},
+			objects: []string{
+				"a/AuthorizationPolicy/web",
+				"a/HTTPRoute/web",
+				"a/MeshTLSAuthentication/clients",
+				"a/NetworkAuthentication/cluster",
+				"a/ServerAuthorization/web",
+				"a/Service/web",
+			},
+		},
+		{
+			name: "unknown kinds and empty documents are skipped",
+			manifests: `
+# comment only
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Server
+metadata:
+  name: web
+`,
+			pods: []string{},
+		},
+		{
+			name:      "invalid document",
+			manifests: "apiVersion: v1\nkind: Pod\nmetadata: [\n",
+			err:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			resources, err := LoadManifests(tc.namespace, "linkerd", strings.NewReader(tc.manifests))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assertStrings(t, "pods", tc.pods, describeManifestPods(resources))
+			assertStrings(t, "objects", tc.objects, describeManifestObjects(resources))
+		})
+	}
+}
+
+// describeManifestObjects returns references of loaded objects besides pods and workloads
+func describeManifestObjects(resources *K8sResources) []string {
+	var objects []string
+	for _, ns := range resources.Namespaces.Items {
+		objects = append(objects, "Namespace/"+ns.Name)
+	}
+	for _, svc := range resources.Services.Items {
+		objects = append(objects, ObjectReference{Kind: "Service", Namespace: svc.Namespace, Name: svc.Name}.String())
+	}
+	for _, obj := range resources.Servers {
+		objects = append(objects, ObjectReference{Kind: ServerKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	for _, obj := range resources.ServerAuthorizations {
+		objects = append(objects, ObjectReference{Kind: ServerAuthorizationKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	for _, obj := range resources.AuthorizationPolicies {
+		objects = append(objects, ObjectReference{Kind: AuthorizationPolicyKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	for _, obj := range resources.HTTPRoutes {
+		objects = append(objects, ObjectReference{Kind: HTTPRouteKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	for _, obj := range resources.MeshTLSAuthentications {
+		objects = append(objects, ObjectReference{Kind: MeshTLSAuthenticationKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	for _, obj := range resources.NetworkAuthentications {
+		objects = append(objects, ObjectReference{Kind: NetworkAuthenticationKind, Namespace: obj.Namespace, Name: obj.Name}.String())
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+func TestLoadManifestFiles(t *testing.T) {
+	dir := t.TempDir()
+	pod := func(name string) string {
+		return "apiVersion: v1\nkind: Pod\nmetadata:\n  name: " + name + "\n"
+	}
+
+	files := map[string]string{
+		"web.yaml":        pod("web"),
+		"nested/api.yml":  pod("api"),
+		"nested/db.json":  `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "db"}}`,
+		"README.md":       pod("readme"),
+		"explicit.txt":    pod("explicit"),
+		"nested/notes.tx": pod("notes"),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// directories are read recursively by extension, explicitly provided files whatever their extension is
+	resources, err := LoadManifestFiles([]string{dir, filepath.Join(dir, "explicit.txt")}, "app", "linkerd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	assertStrings(t, "pods", []string{"app/api", "app/db", "app/explicit", "app/web"}, describeManifestPods(resources))
+}
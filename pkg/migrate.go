@@ -0,0 +1,219 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+)
+
+// Migration holds objects that replace ServerAuthorizations
+type Migration struct {
+	AuthorizationPolicies  []*policy.AuthorizationPolicy
+	MeshTLSAuthentications []*policy.MeshTLSAuthentication
+	NetworkAuthentications []*policy.NetworkAuthentication
+	// Migrated are ServerAuthorizations that can be deleted after new objects are applied
+	Migrated []ObjectReference
+	Warnings []string
+}
+
+// MigrateServerAuthorizations converts ServerAuthorizations to AuthorizationPolicies with authentications,
+// ServerAuthorizations that select Servers by labels produce a policy per selected Server.
+// ServerAuthorizations without an exact equivalent or with names of generated objects taken by other objects are skipped
+// with a warning and are not listed in Migrated
+func MigrateServerAuthorizations(resources *K8sResources) (*Migration, error) {
+	migration := &Migration{}
+
+	for _, serverAuthorization := range resources.ServerAuthorizations {
+		ref := ObjectReference{Kind: ServerAuthorizationKind, Namespace: serverAuthorization.Namespace, Name: serverAuthorization.Name}
+
+		targets, err := serverAuthorizationTargets(resources, serverAuthorization)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(targets) == 0 {
+			migration.Warnings = append(migration.Warnings, fmt.Sprintf("%s doesn't select any Server, skipped", ref))
+			continue
+		}
+
+		// AuthorizationPolicy can't require TLS without identity, a policy without mesh TLS would allow plaintext clients too
+		if client := serverAuthorization.Spec.Client; !client.Unauthenticated && client.MeshTLS != nil && client.MeshTLS.UnauthenticatedTLS {
+			migration.Warnings = append(migration.Warnings, fmt.Sprintf("%s: unauthenticatedTLS has no equivalent, skipped", ref))
+			continue
+		}
+
+		migrated := &Migration{}
+		authnRefs := migrated.addAuthentications(serverAuthorization)
+
+		for _, target := range targets {
+			name := serverAuthorization.Name
+			if len(targets) > 1 {
+				name = serverAuthorization.Name + "-" + target
+			}
+
+			migrated.AuthorizationPolicies = append(migrated.AuthorizationPolicies, &policy.AuthorizationPolicy{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: policy.SchemeGroupVersion.String(),
+					Kind:       AuthorizationPolicyKind,
+				},
+				ObjectMeta: migratedObjectMeta(serverAuthorization, name),
+				Spec: policy.AuthorizationPolicySpec{
+					TargetRef: gatewayapiv1alpha2.PolicyTargetReference{
+						Group: k8s.PolicyAPIGroup,
+						Kind:  k8s.ServerKind,
+						Name:  gatewayapiv1alpha2.ObjectName(target),
+					},
+					RequiredAuthenticationRefs: authnRefs,
+				},
+			})
+		}
+
+		if conflicts := migration.conflicts(resources, migrated); len(conflicts) > 0 {
+			for _, conflict := range conflicts {
+				migration.Warnings = append(migration.Warnings, fmt.Sprintf("%s: %s already exists, skipped", ref, conflict))
+			}
+			continue
+		}
+
+		migration.AuthorizationPolicies = append(migration.AuthorizationPolicies, migrated.AuthorizationPolicies...)
+		migration.MeshTLSAuthentications = append(migration.MeshTLSAuthentications, migrated.MeshTLSAuthentications...)
+		migration.NetworkAuthentications = append(migration.NetworkAuthentications, migrated.NetworkAuthentications...)
+		migration.Migrated = append(migration.Migrated, ref)
+	}
+
+	return migration, nil
+}
+
+// objects returns references to generated objects
+func (m *Migration) objects() []ObjectReference {
+	var refs []ObjectReference
+	for _, obj := range m.AuthorizationPolicies {
+		refs = append(refs, ObjectReference{Kind: AuthorizationPolicyKind, Namespace: obj.Namespace, Name: obj.Name})
+	}
+	for _, obj := range m.MeshTLSAuthentications {
+		refs = append(refs, ObjectReference{Kind: MeshTLSAuthenticationKind, Namespace: obj.Namespace, Name: obj.Name})
+	}
+	for _, obj := range m.NetworkAuthentications {
+		refs = append(refs, ObjectReference{Kind: NetworkAuthenticationKind, Namespace: obj.Namespace, Name: obj.Name})
+	}
+	return refs
+}
+
+// conflicts returns objects of the migration that already exist in resources or were generated for another ServerAuthorization
+func (m *Migration) conflicts(resources *K8sResources, migrated *Migration) []ObjectReference {
+	generated := map[ObjectReference]bool{}
+	for _, ref := range m.objects() {
+		generated[ref] = true
+	}
+
+	var conflicts []ObjectReference
+	for _, ref := range migrated.objects() {
+		if generated[ref] || resources.Object(ref) != nil {
+			conflicts = append(conflicts, ref)
+		}
+	}
+	return conflicts
+}
+
+// serverAuthorizationTargets returns names of Servers that the ServerAuthorization applies to,
+// a Server referenced by name is a target even if it is not found
+func serverAuthorizationTargets(resources *K8sResources, serverAuthorization *saz.ServerAuthorization) ([]string, error) {
+	if serverAuthorization.Spec.Server.Name != "" {
+		return []string{serverAuthorization.Spec.Server.Name}, nil
+	}
+
+	var targets []string
+	for _, srv := range resources.Servers {
+		applies, err := ServerAuthorizationAppliesToServer(serverAuthorization, srv)
+		if err != nil {
+			return nil, err
+		}
+
+		if applies {
+			targets = append(targets, srv.Name)
+		}
+	}
+
+	return targets, nil
+}
+
+// addAuthentications creates authentications for clients of the ServerAuthorization and returns references to them,
+// no references mean that all clients are allowed. unauthenticatedTLS clients are expected to be filtered out by the caller
+func (m *Migration) addAuthentications(serverAuthorization *saz.ServerAuthorization) []gatewayapiv1alpha2.PolicyTargetReference {
+	refs := []gatewayapiv1alpha2.PolicyTargetReference{}
+
+	client := serverAuthorization.Spec.Client
+
+	if len(client.Networks) > 0 {
+		authn := &policy.NetworkAuthentication{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: policy.SchemeGroupVersion.String(),
+				Kind:       NetworkAuthenticationKind,
+			},
+			ObjectMeta: migratedObjectMeta(serverAuthorization, serverAuthorization.Name+"-network"),
+		}
+
+		for _, network := range client.Networks {
+			authn.Spec.Networks = append(authn.Spec.Networks, &policy.Network{Cidr: network.Cidr, Except: network.Except})
+		}
+
+		m.NetworkAuthentications = append(m.NetworkAuthentications, authn)
+		refs = append(refs, gatewayapiv1alpha2.PolicyTargetReference{
+			Group: k8s.PolicyAPIGroup,
+			Kind:  NetworkAuthenticationKind,
+			Name:  gatewayapiv1alpha2.ObjectName(authn.Name),
+		})
+	}
+
+	if client.Unauthenticated || client.MeshTLS == nil {
+		return refs
+	}
+
+	authn := &policy.MeshTLSAuthentication{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policy.SchemeGroupVersion.String(),
+			Kind:       MeshTLSAuthenticationKind,
+		},
+		ObjectMeta: migratedObjectMeta(serverAuthorization, serverAuthorization.Name),
+		Spec: policy.MeshTLSAuthenticationSpec{
+			Identities: client.MeshTLS.Identities,
+		},
+	}
+
+	for _, sa := range client.MeshTLS.ServiceAccounts {
+		identityRef := gatewayapiv1alpha2.PolicyTargetReference{
+			Kind: ServiceAccountKind,
+			Name: gatewayapiv1alpha2.ObjectName(sa.Name),
+		}
+		if sa.Namespace != "" && sa.Namespace != serverAuthorization.Namespace {
+			namespace := gatewayapiv1alpha2.Namespace(sa.Namespace)
+			identityRef.Namespace = &namespace
+		}
+		authn.Spec.IdentityRefs = append(authn.Spec.IdentityRefs, identityRef)
+	}
+
+	// meshTLS without identities and service accounts allows any authenticated client
+	if len(authn.Spec.Identities) == 0 && len(authn.Spec.IdentityRefs) == 0 {
+		authn.Spec.Identities = []string{"*"}
+	}
+
+	m.MeshTLSAuthentications = append(m.MeshTLSAuthentications, authn)
+	refs = append(refs, gatewayapiv1alpha2.PolicyTargetReference{
+		Group: k8s.PolicyAPIGroup,
+		Kind:  MeshTLSAuthenticationKind,
+		Name:  gatewayapiv1alpha2.ObjectName(authn.Name),
+	})
+
+	return refs
+}
+
+func migratedObjectMeta(serverAuthorization *saz.ServerAuthorization, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: serverAuthorization.Namespace,
+		Name:      name,
+		Labels:    serverAuthorization.Labels,
+	}
+}
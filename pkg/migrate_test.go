@@ -0,0 +1,93 @@
+package common
+
+import (
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"testing"
+)
+
+func TestMigrateServerAuthorizations(t *testing.T) {
+	testCases := []struct {
+		name      string
+		resources func(r *K8sResources)
+		migrated  []string
+		generated []string
+		warnings  int
+	}{
+		{
+			name: "mesh TLS and networks",
+			resources: func(r *K8sResources) {
+				r.ServerAuthorizations = append(r.ServerAuthorizations, testServerAuthorization("a", "web", "web", saz.Client{
+					Networks: []*saz.Network{{Cidr: "10.0.0.0/8"}},
+					MeshTLS:  &saz.MeshTLS{ServiceAccounts: []*saz.ServiceAccountName{{Name: "client"}}},
+				}))
+			},
+			migrated:  []string{"a/ServerAuthorization/web"},
+			generated: []string{"a/AuthorizationPolicy/web", "a/MeshTLSAuthentication/web", "a/NetworkAuthentication/web-network"},
+		},
+		{
+			name: "unauthenticated TLS is skipped",
+			resources: func(r *K8sResources) {
+				r.ServerAuthorizations = append(r.ServerAuthorizations, testServerAuthorization("a", "web", "web", saz.Client{
+					Networks: []*saz.Network{{Cidr: "10.0.0.0/8"}},
+					MeshTLS:  &saz.MeshTLS{UnauthenticatedTLS: true},
+				}))
+			},
+			migrated:  []string{},
+			generated: []string{},
+			warnings:  1,
+		},
+		{
+			name: "existing authentication is not overwritten",
+			resources: func(r *K8sResources) {
+				r.ServerAuthorizations = append(r.ServerAuthorizations, testServerAuthorization("a", "web", "web", saz.Client{
+					Networks: []*saz.Network{{Cidr: "10.0.0.0/8"}},
+				}))
+				r.NetworkAuthentications = append(r.NetworkAuthentications, testNetworkAuthentication("a", "web-network"))
+			},
+			migrated:  []string{},
+			generated: []string{},
+			warnings:  1,
+		},
+		{
+			name: "existing object in another namespace is not a conflict",
+			resources: func(r *K8sResources) {
+				r.ServerAuthorizations = append(r.ServerAuthorizations, testServerAuthorization("a", "web", "web", saz.Client{
+					Unauthenticated: true,
+				}))
+				r.AuthorizationPolicies = append(r.AuthorizationPolicies, testPolicy("b", "web", ServerKind, "web"))
+			},
+			migrated:  []string{"a/ServerAuthorization/web"},
+			generated: []string{"a/AuthorizationPolicy/web"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			resources := testResources()
+			tc.resources(resources)
+
+			migration, err := MigrateServerAuthorizations(resources)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assertReferences(t, "migrated", tc.migrated, migration.Migrated)
+			assertReferences(t, "generated", tc.generated, migration.objects())
+
+			if len(migration.Warnings) != tc.warnings {
+				t.Errorf("expected %d warnings, got %v", tc.warnings, migration.Warnings)
+			}
+		})
+	}
+}
+
+func assertReferences(t *testing.T, what string, expected []string, refs []ObjectReference) {
+	t.Helper()
+
+	actual := []string{}
+	for _, ref := range refs {
+		actual = append(actual, ref.String())
+	}
+	assertStrings(t, what, expected, actual)
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	"github.com/linkerd/linkerd2/controller/gen/client/clientset/versioned"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+)
+
+const (
+	// PruneExcludeAnnotation protects intentionally pre-created policies from prune
+	PruneExcludeAnnotation = "easyauth.linkerd.io/prune-exclude"
+)
+
+// pruneRules are checks whose findings are objects that can be deleted
+var pruneRules = map[string]bool{
+	PolicyWithoutServerRule:    true,
+	ObsoleteHTTPRouteRule:      true,
+	ObsoleteAuthenticationRule: true,
+}
+
+// PruneCandidate is an object flagged by one of the obsolete resources checks
+type PruneCandidate struct {
+	Object   ObjectReference
+	Excluded bool
+	Findings []Finding
+}
+
+// PruneCandidates runs obsolete resources checks and returns flagged objects of the namespace (or of all namespaces
+// if it is empty) sorted by namespace, kind and name, objects with the exclusion annotation are returned as excluded.
+// Objects labeled by easyauth controllers are skipped, their controller garbage-collects them.
+// Resources should contain all namespaces, so references from other namespaces are seen
+func PruneCandidates(resources *K8sResources, namespace, controlPlaneNamespace string) ([]PruneCandidate, error) {
+	candidates := map[string]*PruneCandidate{}
+
+	for _, check := range EasyAuthChecks(controlPlaneNamespace) {
+		if !pruneRules[check.ID] {
+			continue
+		}
+
+		findings, err := check.RunPerNamespace(resources)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, finding := range findings {
+			if namespace != "" && finding.Object.Namespace != namespace {
+				continue
+			}
+
+			if isManagedObject(resources, finding.Object) {
+				continue
+			}
+
+			// obsolete HTTPRoute findings are about single parentRefs, a route with a live parent still carries traffic
+			if finding.Object.Kind == HTTPRouteKind && httpRouteHasServer(resources, finding.Object) {
+				continue
+			}
+
+			candidate, ok := candidates[finding.Object.String()]
+			if !ok {
+				candidate = &PruneCandidate{
+					Object:   finding.Object,
+					Excluded: isPruneExcluded(resources, finding.Object),
+				}
+				candidates[finding.Object.String()] = candidate
+			}
+			candidate.Findings = append(candidate.Findings, finding)
+		}
+	}
+
+	result := make([]PruneCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, *candidate)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Object, result[j].Object
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	return result, nil
+}
+
+// KubectlResource returns the fully qualified resource name of a policy object, e.g. httproute.policy.linkerd.io/web,
+// the group is required because HTTPRoute is also a Gateway API kind
+func KubectlResource(object ObjectReference) string {
+	return fmt.Sprintf("%s.%s/%s", strings.ToLower(object.Kind), k8s.PolicyAPIGroup, object.Name)
+}
+
+// DeletePolicyObject deletes a policy object flagged by prune or managed by a controller, dryRun asks the API server not to persist the deletion
+func DeletePolicyObject(ctx context.Context, client versioned.Interface, object ObjectReference, dryRun bool) error {
+	opts := metav1.DeleteOptions{}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	switch object.Kind {
+	case ServerKind:
+		return client.ServerV1beta1().Servers(object.Namespace).Delete(ctx, object.Name, opts)
+	case ServerAuthorizationKind:
+		return client.ServerauthorizationV1beta1().ServerAuthorizations(object.Namespace).Delete(ctx, object.Name, opts)
+	case AuthorizationPolicyKind:
+		return client.PolicyV1alpha1().AuthorizationPolicies(object.Namespace).Delete(ctx, object.Name, opts)
+	case HTTPRouteKind:
+		return client.PolicyV1alpha1().HTTPRoutes(object.Namespace).Delete(ctx, object.Name, opts)
+	case MeshTLSAuthenticationKind:
+		return client.PolicyV1alpha1().MeshTLSAuthentications(object.Namespace).Delete(ctx, object.Name, opts)
+	case NetworkAuthenticationKind:
+		return client.PolicyV1alpha1().NetworkAuthentications(object.Namespace).Delete(ctx, object.Name, opts)
+	}
+
+	return fmt.Errorf("unsupported kind %s", object.Kind)
+}
+
+func isPruneExcluded(resources *K8sResources, object ObjectReference) bool {
+	accessor := objectMeta(resources, object)
+	return accessor != nil && accessor.GetAnnotations()[PruneExcludeAnnotation] == "true"
+}
+
+func isManagedObject(resources *K8sResources, object ObjectReference) bool {
+	accessor := objectMeta(resources, object)
+	return accessor != nil && accessor.GetLabels()[ManagedByLabel] != ""
+}
+
+func objectMeta(resources *K8sResources, object ObjectReference) metav1.Object {
+	obj := resources.Object(object)
+	if obj == nil {
+		return nil
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+
+	return accessor
+}
+
+// httpRouteHasServer tells if at least one parentRef of the HTTPRoute points to an existing Server
+func httpRouteHasServer(resources *K8sResources, object ObjectReference) bool {
+	route, ok := resources.Object(object).(*policy.HTTPRoute)
+	if !ok {
+		return false
+	}
+
+	for _, srv := range resources.Servers {
+		if RouteAttachedToServer(route, srv) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	gatewayapiv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	"testing"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	resources := testResources()
+	resources.Servers = append(resources.Servers, testServer("a", "web", "web", 8080))
+
+	excluded := testHTTPRoute("a", "excluded", testServerParentRef("gone"))
+	excluded.Annotations = map[string]string{PruneExcludeAnnotation: "true"}
+
+	resources.HTTPRoutes = append(resources.HTTPRoutes,
+		testHTTPRoute("a", "live", testServerParentRef("web"), testServerParentRef("gone")),
+		testHTTPRoute("a", "dangling", testServerParentRef("gone"), testServerParentRef("removed")),
+		excluded,
+	)
+
+	candidates, err := PruneCandidates(resources, "", "linkerd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []struct {
+		object   string
+		excluded bool
+		findings int
+	}{
+		{object: "a/HTTPRoute/dangling", findings: 2},
+		{object: "a/HTTPRoute/excluded", excluded: true, findings: 1},
+	}
+
+	if len(candidates) != len(expected) {
+		t.Fatalf("expected %d candidates, got %v", len(expected), candidates)
+	}
+
+	for i, exp := range expected {
+		candidate := candidates[i]
+		if candidate.Object.String() != exp.object || candidate.Excluded != exp.excluded || len(candidate.Findings) != exp.findings {
+			t.Errorf("expected %s (excluded %t) with %d findings, got %s (excluded %t) with %d findings",
+				exp.object, exp.excluded, exp.findings, candidate.Object, candidate.Excluded, len(candidate.Findings))
+		}
+	}
+}
+
+func TestPruneCandidatesResolveAuthenticationRefs(t *testing.T) {
+	resources := testResources()
+	resources.Servers = append(resources.Servers, testServer("a", "web", "web", 8080), testServer("b", "web", "web", 8080))
+	resources.MeshTLSAuthentications = append(resources.MeshTLSAuthentications,
+		testMeshTLSAuthentication("shared", "clients", "*"),
+		testMeshTLSAuthentication("a", "web", "*"),
+		testMeshTLSAuthentication("b", "unused", "*"),
+	)
+	resources.NetworkAuthentications = append(resources.NetworkAuthentications, testNetworkAuthentication("a", "clients", "10.0.0.0/8"))
+
+	crossNamespace := testPolicy("a", "web", ServerKind, "web")
+	shared := gatewayapiv1alpha2.Namespace("shared")
+	crossNamespace.Spec.RequiredAuthenticationRefs = []gatewayapiv1alpha2.PolicyTargetReference{
+		{Group: k8s.PolicyAPIGroup, Kind: MeshTLSAuthenticationKind, Name: "clients", Namespace: &shared},
+		{Group: k8s.PolicyAPIGroup, Kind: MeshTLSAuthenticationKind, Name: "web"},
+	}
+	resources.AuthorizationPolicies = append(resources.AuthorizationPolicies, crossNamespace)
+
+	testCases := []struct {
+		namespace string
+		expected  []string
+	}{
+		// the NetworkAuthentication has the name of a required MeshTLSAuthentication only
+		{namespace: "", expected: []string{"a/NetworkAuthentication/clients", "b/MeshTLSAuthentication/unused"}},
+		{namespace: "a", expected: []string{"a/NetworkAuthentication/clients"}},
+		{namespace: "shared", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		candidates, err := PruneCandidates(resources, tc.namespace, "linkerd")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		actual := []string{}
+		for _, candidate := range candidates {
+			actual = append(actual, candidate.Object.String())
+		}
+		assertStrings(t, "candidates of namespace "+tc.namespace, tc.expected, actual)
+	}
+}
+
+func TestPruneCandidatesSkipManagedObjects(t *testing.T) {
+	resources := testResources()
+
+	managed := testNetworkAuthentication("a", "cluster-network-authn", "10.0.0.0/8")
+	managed.Labels = map[string]string{ManagedByLabel: ManagedByBaselineController}
+	resources.NetworkAuthentications = append(resources.NetworkAuthentications, managed, testNetworkAuthentication("a", "unused", "10.0.0.0/8"))
+
+	candidates, err := PruneCandidates(resources, "", "linkerd")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	actual := []string{}
+	for _, candidate := range candidates {
+		actual = append(actual, candidate.Object.String())
+	}
+	assertStrings(t, "candidates", []string{"a/NetworkAuthentication/unused"}, actual)
+}
@@ -0,0 +1,331 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	pathMatchExact             = "Exact"
+	pathMatchPathPrefix        = "PathPrefix"
+	pathMatchRegularExpression = "RegularExpression"
+)
+
+// AccessRequest is a request from a client to a pod port
+type AccessRequest struct {
+	Client Client
+	Pod    v1.Pod
+	Port   string
+	Path   string
+	Method string
+	// ClusterDefaultPolicy is the default inbound policy of the Linkerd installation
+	ClusterDefaultPolicy string
+	ClusterNetworks      []string
+}
+
+// AccessStep is an object that took part in the decision
+type AccessStep struct {
+	Object ObjectReference `json:"object"`
+	Detail string          `json:"detail"`
+}
+
+// AccessDecision is the result of access evaluation with the chain of objects that decided it,
+// Rejections explain why other policies did not admit the client
+type AccessDecision struct {
+	Allowed    bool         `json:"allowed"`
+	Chain      []AccessStep `json:"chain"`
+	Rejections []string     `json:"rejections,omitempty"`
+}
+
+// EvaluateAccess decides if the client is allowed to reach the pod port, it evaluates Servers, HTTPRoutes,
+// AuthorizationPolicies, ServerAuthorizations and authentications and falls back to the default inbound policy
+// of the pod, its namespace or the cluster
+func EvaluateAccess(resources *K8sResources, request AccessRequest) (*AccessDecision, error) {
+	containerPort, ok := findContainerPort(request.Pod, request.Port)
+	if !ok {
+		return nil, fmt.Errorf("pod %s/%s has no port %s", request.Pod.Namespace, request.Pod.Name, request.Port)
+	}
+
+	decision := &AccessDecision{}
+	podRef := ObjectReference{Kind: PodKind, Namespace: request.Pod.Namespace, Name: request.Pod.Name}
+
+	srv, err := serverForPort(resources, request.Pod, containerPort)
+	if err != nil {
+		return nil, err
+	}
+
+	if srv == nil {
+		decision.Chain = append(decision.Chain, AccessStep{Object: podRef, Detail: fmt.Sprintf("port %d is not covered by any Server", containerPort.ContainerPort)})
+		return evaluateDefaultPolicy(resources, request, decision), nil
+	}
+
+	serverStep := AccessStep{
+		Object: ObjectReference{Kind: ServerKind, Namespace: srv.Namespace, Name: srv.Name},
+		Detail: fmt.Sprintf("selects pod %s port %s", request.Pod.Name, srv.Spec.Port.String()),
+	}
+
+	var matchedRoute *policy.HTTPRoute
+	var routeStep *AccessStep
+
+	routes := serverRoutes(resources, srv)
+	if len(routes) > 0 && isHTTPServer(srv) {
+		for _, route := range routes {
+			if routeMatches(route, request.Path, request.Method) {
+				matchedRoute = route
+				routeStep = &AccessStep{
+					Object: ObjectReference{Kind: HTTPRouteKind, Namespace: route.Namespace, Name: route.Name},
+					Detail: fmt.Sprintf("matches %s %s", request.Method, request.Path),
+				}
+				break
+			}
+		}
+
+		if matchedRoute == nil {
+			decision.Chain = append(decision.Chain, serverStep)
+			decision.Rejections = append(decision.Rejections, fmt.Sprintf("no HTTPRoute of Server %s matches %s %s", srv.Name, request.Method, request.Path))
+			return decision, nil
+		}
+	}
+
+	rules, err := AccessRulesForServer(resources, srv)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.Route != nil && rule.Route != matchedRoute {
+			continue
+		}
+
+		policyRef := rule.Policy.Kind + " " + rule.Policy.Name
+
+		if len(rule.MissingAuthentications) > 0 {
+			decision.Rejections = append(decision.Rejections, fmt.Sprintf("%s refers to missing %s", policyRef, rule.MissingAuthentications[0]))
+			continue
+		}
+
+		admittedBy, ok := rule.Clients.Admits(request.Client)
+		if !ok {
+			decision.Rejections = append(decision.Rejections, fmt.Sprintf("%s does not admit %s", policyRef, describeClient(request.Client)))
+			continue
+		}
+
+		network, ok := rule.Clients.AdmitsNetwork(request.Client)
+		if !ok {
+			decision.Rejections = append(decision.Rejections, fmt.Sprintf("%s does not admit client IPs %s", policyRef, strings.Join(request.Client.IPs, ", ")))
+			continue
+		}
+
+		decision.Allowed = true
+		decision.Rejections = nil
+		decision.Chain = append(decision.Chain, serverStep)
+		if routeStep != nil {
+			decision.Chain = append(decision.Chain, *routeStep)
+		}
+
+		detail := "admits " + admittedBy
+		if network != "" {
+			detail += " from " + network
+		}
+		decision.Chain = append(decision.Chain, AccessStep{Object: rule.Policy, Detail: detail})
+
+		for _, authn := range rule.Authentications {
+			decision.Chain = append(decision.Chain, AccessStep{Object: authn, Detail: "required authentication"})
+		}
+
+		return decision, nil
+	}
+
+	decision.Chain = append(decision.Chain, serverStep)
+	if routeStep != nil {
+		decision.Chain = append(decision.Chain, *routeStep)
+	}
+
+	if len(rules) == 0 {
+		decision.Rejections = append(decision.Rejections, fmt.Sprintf("no policy targets Server %s, all requests are denied", srv.Name))
+	}
+
+	return decision, nil
+}
+
+func evaluateDefaultPolicy(resources *K8sResources, request AccessRequest, decision *AccessDecision) *AccessDecision {
+	defaultPolicy := request.ClusterDefaultPolicy
+	step := AccessStep{Object: ObjectReference{Kind: "Cluster", Name: "default-inbound-policy"}}
+
+	if ns := resources.Namespace(request.Pod.Namespace); ns != nil && ns.Annotations[DefaultInboundPolicyAnnotation] != "" {
+		defaultPolicy = ns.Annotations[DefaultInboundPolicyAnnotation]
+		step.Object = ObjectReference{Kind: NamespaceKind, Name: ns.Name}
+	}
+
+	if request.Pod.Annotations[DefaultInboundPolicyAnnotation] != "" {
+		defaultPolicy = request.Pod.Annotations[DefaultInboundPolicyAnnotation]
+		step.Object = ObjectReference{Kind: PodKind, Namespace: request.Pod.Namespace, Name: request.Pod.Name}
+	}
+
+	inCluster := len(request.Client.IPs) == 0
+	for _, ip := range request.Client.IPs {
+		for _, cidr := range request.ClusterNetworks {
+			if cidrContains(cidr, net.ParseIP(ip)) {
+				inCluster = true
+			}
+		}
+	}
+
+	step.Detail = "default inbound policy " + defaultPolicy
+
+	switch defaultPolicy {
+	case AllUnauthenticatedPolicy, AuditPolicy:
+		decision.Allowed = true
+	case AllAuthenticatedPolicy:
+		decision.Allowed = request.Client.Identity != ""
+	case ClusterUnauthenticatedPolicy:
+		decision.Allowed = inCluster
+	case ClusterAuthenticatedPolicy:
+		decision.Allowed = inCluster && request.Client.Identity != ""
+	case DenyPolicy:
+		decision.Allowed = false
+	default:
+		decision.Rejections = append(decision.Rejections, fmt.Sprintf("unknown default inbound policy %q", defaultPolicy))
+	}
+
+	decision.Chain = append(decision.Chain, step)
+
+	if !decision.Allowed && request.Client.Identity == "" {
+		decision.Rejections = append(decision.Rejections, describeClient(request.Client)+" has no mesh identity")
+	}
+
+	return decision
+}
+
+func describeClient(client Client) string {
+	if client.Identity == "" {
+		return fmt.Sprintf("unmeshed client %s/%s", client.Namespace, client.ServiceAccount)
+	}
+	return "identity " + client.Identity
+}
+
+func findContainerPort(pod v1.Pod, port string) (v1.ContainerPort, bool) {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == port || strconv.Itoa(int(p.ContainerPort)) == port {
+				return p, true
+			}
+		}
+	}
+	return v1.ContainerPort{}, false
+}
+
+// serverForPort returns the oldest Server that selects the pod port as Linkerd does in case of conflicts
+func serverForPort(resources *K8sResources, pod v1.Pod, containerPort v1.ContainerPort) (*server.Server, error) {
+	var servers []*server.Server
+
+	for _, srv := range resources.Servers {
+		if srv.Namespace != pod.Namespace || srv.Spec.PodSelector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(srv.Spec.PodSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create selector: %w", err)
+		}
+
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		if p, ok := ServerContainerPort(srv, pod); ok && p.ContainerPort == containerPort.ContainerPort {
+			servers = append(servers, srv)
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(servers, func(i, j int) bool {
+		return servers[i].CreationTimestamp.Before(&servers[j].CreationTimestamp)
+	})
+
+	return servers[0], nil
+}
+
+func serverRoutes(resources *K8sResources, srv *server.Server) []*policy.HTTPRoute {
+	var routes []*policy.HTTPRoute
+	for _, route := range resources.HTTPRoutes {
+		if RouteAttachedToServer(route, srv) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+func isHTTPServer(srv *server.Server) bool {
+	switch srv.Spec.ProxyProtocol {
+	case "opaque", "TLS":
+		return false
+	}
+	return true
+}
+
+// routeMatches is true if any rule of the HTTPRoute matches the path and method, header and query
+// parameter matches are not evaluated
+func routeMatches(route *policy.HTTPRoute, path, method string) bool {
+	if len(route.Spec.Rules) == 0 {
+		return true
+	}
+
+	for _, rule := range route.Spec.Rules {
+		if len(rule.Matches) == 0 {
+			return true
+		}
+
+		for _, match := range rule.Matches {
+			if match.Method != nil && !strings.EqualFold(string(*match.Method), method) {
+				continue
+			}
+
+			if match.Path == nil {
+				return true
+			}
+
+			matchType := pathMatchPathPrefix
+			if match.Path.Type != nil {
+				matchType = string(*match.Path.Type)
+			}
+
+			value := "/"
+			if match.Path.Value != nil {
+				value = *match.Path.Value
+			}
+
+			if pathMatches(matchType, value, path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func pathMatches(matchType, value, path string) bool {
+	switch matchType {
+	case pathMatchExact:
+		return path == value
+	case pathMatchPathPrefix:
+		prefix := strings.TrimSuffix(value, "/")
+		return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+	case pathMatchRegularExpression:
+		matched, err := regexp.MatchString("^(?:"+value+")$", path)
+		return err == nil && matched
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package common
+
+import (
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"testing"
+)
+
+const reachManifests = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app: web
+    linkerd.io/control-plane-ns: linkerd
+spec:
+  containers:
+  - name: web
+    ports:
+    - name: http
+      containerPort: 8080
+    - name: admin
+      containerPort: 9990
+---
+apiVersion: policy.linkerd.io/v1beta1
+kind: Server
+metadata:
+  name: web-http
+  namespace: app
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  port: http
+---
+apiVersion: policy.linkerd.io/v1alpha1
+kind: HTTPRoute
+metadata:
+  name: web-api
+  namespace: app
+spec:
+  parentRefs:
+  - group: policy.linkerd.io
+    kind: Server
+    name: web-http
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /api
+---
+apiVersion: policy.linkerd.io/v1alpha1
+kind: AuthorizationPolicy
+metadata:
+  name: web-api
+  namespace: app
+spec:
+  targetRef:
+    group: policy.linkerd.io
+    kind: HTTPRoute
+    name: web-api
+  requiredAuthenticationRefs:
+  - group: policy.linkerd.io
+    kind: MeshTLSAuthentication
+    name: clients
+---
+apiVersion: policy.linkerd.io/v1alpha1
+kind: MeshTLSAuthentication
+metadata:
+  name: clients
+  namespace: app
+spec:
+  identities:
+  - client.app.serviceaccount.identity.linkerd.cluster.local
+`
+
+func TestEvaluateAccess(t *testing.T) {
+	resources, err := LoadManifests("app", "linkerd", strings.NewReader(reachManifests))
+	if err != nil {
+		t.Fatalf("failed to load manifests: %s", err)
+	}
+
+	testCases := []struct {
+		name        string
+		client      Client
+		port        string
+		path        string
+		annotations map[string]string
+		allowed     bool
+		chain       []string
+		err         bool
+	}{
+		{
+			name:    "client admitted through the HTTPRoute policy",
+			client:  testClient("app", "client"),
+			port:    "http",
+			path:    "/api/users",
+			allowed: true,
+			chain:   []string{"app/Server/web-http", "app/HTTPRoute/web-api", "app/AuthorizationPolicy/web-api", "app/MeshTLSAuthentication/clients"},
+		},
+		{
+			name:   "client not admitted by the policy",
+			client: testClient("app", "other"),
+			port:   "8080",
+			path:   "/api/users",
+			chain:  []string{"app/Server/web-http", "app/HTTPRoute/web-api"},
+		},
+		{
+			name:   "no HTTPRoute matches the path",
+			client: testClient("app", "client"),
+			port:   "http",
+			path:   "/metrics",
+			chain:  []string{"app/Server/web-http"},
+		},
+		{
+			name:    "port without Server falls back to the cluster default policy",
+			client:  testClient("app", "other"),
+			port:    "admin",
+			allowed: true,
+			chain:   []string{"app/Pod/web", "Cluster/default-inbound-policy"},
+		},
+		{
+			name:        "port without Server falls back to the pod default policy",
+			client:      testClient("app", "client"),
+			port:        "9990",
+			annotations: map[string]string{DefaultInboundPolicyAnnotation: DenyPolicy},
+			chain:       []string{"app/Pod/web", "app/Pod/web"},
+		},
+		{
+			name: "unknown port",
+			port: "9999",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pod := *resources.Pods.Items[0].DeepCopy()
+			pod.Annotations = tc.annotations
+
+			decision, err := EvaluateAccess(resources, AccessRequest{
+				Client:               tc.client,
+				Pod:                  pod,
+				Port:                 tc.port,
+				Path:                 tc.path,
+				Method:               "GET",
+				ClusterDefaultPolicy: AllUnauthenticatedPolicy,
+				ClusterNetworks:      DefaultClusterNetworks,
+			})
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if decision.Allowed != tc.allowed {
+				t.Fatalf("expected allowed %t, got %t: %v", tc.allowed, decision.Allowed, decision.Rejections)
+			}
+
+			var chain []string
+			for _, step := range decision.Chain {
+				chain = append(chain, step.Object.String())
+			}
+			assertStrings(t, "chain", tc.chain, chain)
+		})
+	}
+}
+
+func TestEvaluateDefaultPolicy(t *testing.T) {
+	meshed := testClient("app", "client")
+	unmeshed := Client{Namespace: "app", ServiceAccount: "client"}
+	external := testClient("app", "client", "203.0.113.1")
+
+	testCases := []struct {
+		name      string
+		policy    string
+		namespace map[string]string
+		pod       map[string]string
+		client    Client
+		allowed   bool
+		object    string
+	}{
+		{name: "all unauthenticated", policy: AllUnauthenticatedPolicy, client: unmeshed, allowed: true, object: "Cluster/default-inbound-policy"},
+		{name: "audit", policy: AuditPolicy, client: unmeshed, allowed: true, object: "Cluster/default-inbound-policy"},
+		{name: "all authenticated with identity", policy: AllAuthenticatedPolicy, client: meshed, allowed: true, object: "Cluster/default-inbound-policy"},
+		{name: "all authenticated without identity", policy: AllAuthenticatedPolicy, client: unmeshed, object: "Cluster/default-inbound-policy"},
+		{name: "cluster unauthenticated from cluster network", policy: ClusterUnauthenticatedPolicy, client: testClient("app", "client", "10.1.0.1"), allowed: true, object: "Cluster/default-inbound-policy"},
+		{name: "cluster unauthenticated from external network", policy: ClusterUnauthenticatedPolicy, client: external, object: "Cluster/default-inbound-policy"},
+		{name: "cluster authenticated without identity", policy: ClusterAuthenticatedPolicy, client: unmeshed, object: "Cluster/default-inbound-policy"},
+		{name: "deny", policy: DenyPolicy, client: meshed, object: "Cluster/default-inbound-policy"},
+		{name: "unknown policy", policy: "allow", client: meshed, object: "Cluster/default-inbound-policy"},
+		{
+			name:      "namespace annotation overrides the cluster policy",
+			policy:    AllUnauthenticatedPolicy,
+			namespace: map[string]string{DefaultInboundPolicyAnnotation: DenyPolicy},
+			client:    meshed,
+			object:    "Namespace/app",
+		},
+		{
+			name:      "pod annotation overrides the namespace policy",
+			policy:    DenyPolicy,
+			namespace: map[string]string{DefaultInboundPolicyAnnotation: DenyPolicy},
+			pod:       map[string]string{DefaultInboundPolicyAnnotation: AllAuthenticatedPolicy},
+			client:    meshed,
+			allowed:   true,
+			object:    "app/Pod/web",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			resources := testResources()
+			resources.Namespaces.Items = append(resources.Namespaces.Items, testNamespace("app", tc.namespace))
+
+			pod := testMeshedPod("app", "web", 8080)
+			pod.Annotations = tc.pod
+
+			decision := evaluateDefaultPolicy(resources, AccessRequest{
+				Client:               tc.client,
+				Pod:                  pod,
+				ClusterDefaultPolicy: tc.policy,
+				ClusterNetworks:      DefaultClusterNetworks,
+			}, &AccessDecision{})
+
+			if decision.Allowed != tc.allowed {
+				t.Fatalf("expected allowed %t, got %t: %v", tc.allowed, decision.Allowed, decision.Rejections)
+			}
+			if len(decision.Chain) != 1 || decision.Chain[0].Object.String() != tc.object {
+				t.Fatalf("expected decision by %s, got %v", tc.object, decision.Chain)
+			}
+		})
+	}
+}
+
+func testRoute(t *testing.T, spec string) *policy.HTTPRoute {
+	t.Helper()
+
+	route := &policy.HTTPRoute{}
+	if err := yaml.Unmarshal([]byte(spec), &route.Spec); err != nil {
+		t.Fatalf("failed to decode route: %s", err)
+	}
+	return route
+}
+
+func TestRouteMatches(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    string
+		path    string
+		method  string
+		matches bool
+	}{
+		{name: "route without rules", spec: `{}`, path: "/any", method: "GET", matches: true},
+		{name: "rule without matches", spec: `rules: [{}]`, path: "/any", method: "GET", matches: true},
+		{name: "method only", spec: `rules: [{matches: [{method: GET}]}]`, path: "/any", method: "get", matches: true},
+		{name: "other method", spec: `rules: [{matches: [{method: POST}]}]`, path: "/any", method: "GET"},
+		{name: "path type defaults to prefix", spec: `rules: [{matches: [{path: {value: /api}}]}]`, path: "/api/users", method: "GET", matches: true},
+		{name: "path value defaults to root", spec: `rules: [{matches: [{path: {type: PathPrefix}}]}]`, path: "/any", method: "GET", matches: true},
+		{
+			name:    "any match of any rule",
+			spec:    `rules: [{matches: [{path: {type: Exact, value: /health}}]}, {matches: [{method: POST, path: {value: /api}}, {path: {value: /static}}]}]`,
+			path:    "/static/app.js",
+			method:  "GET",
+			matches: true,
+		},
+		{
+			name:   "no match",
+			spec:   `rules: [{matches: [{path: {type: Exact, value: /health}}]}, {matches: [{method: POST, path: {value: /api}}]}]`,
+			path:   "/api",
+			method: "GET",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if matches := routeMatches(testRoute(t, tc.spec), tc.path, tc.method); matches != tc.matches {
+				t.Fatalf("expected %t for %s %s, got %t", tc.matches, tc.method, tc.path, matches)
+			}
+		})
+	}
+}
+
+func TestPathMatches(t *testing.T) {
+	testCases := []struct {
+		matchType string
+		value     string
+		path      string
+		matches   bool
+	}{
+		{matchType: pathMatchExact, value: "/api", path: "/api", matches: true},
+		{matchType: pathMatchExact, value: "/api", path: "/api/"},
+		{matchType: pathMatchPathPrefix, value: "/api", path: "/api", matches: true},
+		{matchType: pathMatchPathPrefix, value: "/api/", path: "/api/users", matches: true},
+		{matchType: pathMatchPathPrefix, value: "/api", path: "/apis"},
+		{matchType: pathMatchPathPrefix, value: "/", path: "/any", matches: true},
+		{matchType: pathMatchRegularExpression, value: "/v[0-9]+/.*", path: "/v1/users", matches: true},
+		{matchType: pathMatchRegularExpression, value: "/v[0-9]+", path: "/v1/users"},
+		{matchType: pathMatchRegularExpression, value: "/v[", path: "/v["},
+		{matchType: "Unknown", value: "/", path: "/"},
+	}
+
+	for _, tc := range testCases {
+		if matches := pathMatches(tc.matchType, tc.value, tc.path); matches != tc.matches {
+			t.Errorf("%s %s: expected %t for %s, got %t", tc.matchType, tc.value, tc.matches, tc.path, matches)
+		}
+	}
+}
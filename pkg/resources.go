@@ -0,0 +1,112 @@
+package common
+
+import (
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	saz "github.com/linkerd/linkerd2/controller/gen/apis/serverauthorization/v1beta1"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	PodKind                   = "Pod"
+	DeploymentKind            = "Deployment"
+	StatefulSetKind           = "StatefulSet"
+	DaemonSetKind             = "DaemonSet"
+	ServerKind                = k8s.ServerKind
+	ServerAuthorizationKind   = "ServerAuthorization"
+	AuthorizationPolicyKind   = "AuthorizationPolicy"
+	HTTPRouteKind             = k8s.HTTPRouteKind
+	MeshTLSAuthenticationKind = "MeshTLSAuthentication"
+	NetworkAuthenticationKind = "NetworkAuthentication"
+)
+
+// K8sResources is a snapshot of all resources that are needed by easyauth checks
+type K8sResources struct {
+	Namespaces             *v1.NamespaceList
+	Pods                   *v1.PodList
+	Services               *v1.ServiceList
+	Deployments            *appsv1.DeploymentList
+	StatefulSets           *appsv1.StatefulSetList
+	DaemonSets             *appsv1.DaemonSetList
+	Servers                []*server.Server
+	ServerAuthorizations   []*saz.ServerAuthorization
+	AuthorizationPolicies  []*policy.AuthorizationPolicy
+	HTTPRoutes             []*policy.HTTPRoute
+	MeshTLSAuthentications []*policy.MeshTLSAuthentication
+	NetworkAuthentications []*policy.NetworkAuthentication
+}
+
+// Namespace returns the namespace by name if it was fetched
+func (r *K8sResources) Namespace(name string) *v1.Namespace {
+	if r.Namespaces == nil {
+		return nil
+	}
+
+	for i := range r.Namespaces.Items {
+		if r.Namespaces.Items[i].Name == name {
+			return &r.Namespaces.Items[i]
+		}
+	}
+	return nil
+}
+
+// Object returns the fetched object that the reference points to, nil if it was not fetched
+func (r *K8sResources) Object(ref ObjectReference) runtime.Object {
+	matches := func(meta *metav1.ObjectMeta) bool {
+		return meta.Namespace == ref.Namespace && meta.Name == ref.Name
+	}
+
+	switch ref.Kind {
+	case PodKind:
+		if r.Pods == nil {
+			return nil
+		}
+		for i := range r.Pods.Items {
+			if matches(&r.Pods.Items[i].ObjectMeta) {
+				return &r.Pods.Items[i]
+			}
+		}
+	case ServerKind:
+		for _, obj := range r.Servers {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	case ServerAuthorizationKind:
+		for _, obj := range r.ServerAuthorizations {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	case AuthorizationPolicyKind:
+		for _, obj := range r.AuthorizationPolicies {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	case HTTPRouteKind:
+		for _, obj := range r.HTTPRoutes {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	case MeshTLSAuthenticationKind:
+		for _, obj := range r.MeshTLSAuthentications {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	case NetworkAuthenticationKind:
+		for _, obj := range r.NetworkAuthentications {
+			if matches(&obj.ObjectMeta) {
+				return obj
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ServersAnnotation lists ports of a workload that need Servers, e.g. "http,grpc:gRPC,8080:opaque"
+	ServersAnnotation = "easyauth.linkerd.io/servers"
+
+	// ManagedByLabel marks objects that are created and garbage-collected by easyauth controllers
+	ManagedByLabel = "easyauth.linkerd.io/managed-by"
+
+	ManagedByServersController = "servers-controller"
+
+	// DefaultProxyProtocol is set by the Server CRD when proxyProtocol is omitted
+	DefaultProxyProtocol = "unknown"
+)
+
+// proxyProtocols are values of Server proxyProtocol that Linkerd accepts
+var proxyProtocols = map[string]bool{
+	DefaultProxyProtocol: true,
+	"HTTP/1":             true,
+	"HTTP/2":             true,
+	"gRPC":               true,
+	"opaque":             true,
+	"TLS":                true,
+}
+
+// ServerPort is a single entry of the servers annotation
+type ServerPort struct {
+	Port          v1.ContainerPort
+	ProxyProtocol string
+}
+
+// ParseServersAnnotation parses comma separated port names or numbers with optional proxy protocols
+func ParseServersAnnotation(value string) ([]ServerPort, error) {
+	var ports []ServerPort
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		port, protocol, _ := strings.Cut(entry, ":")
+		if protocol != "" && !proxyProtocols[protocol] {
+			return nil, fmt.Errorf("unsupported proxy protocol %q for port %s", protocol, port)
+		}
+
+		serverPort := ServerPort{ProxyProtocol: protocol}
+		if number, err := strconv.Atoi(port); err == nil {
+			if number <= 0 || number > 65535 {
+				return nil, fmt.Errorf("invalid port number %d", number)
+			}
+			serverPort.Port.ContainerPort = int32(number)
+		} else {
+			serverPort.Port.Name = port
+		}
+
+		ports = append(ports, serverPort)
+	}
+
+	return ports, nil
+}
+
+// AnnotatedServers returns Servers requested by the servers annotation of deployments, statefulsets and daemonsets.
+// Servers select pods by the workload selector and are owned by the workload, so Kubernetes deletes them together.
+// Workloads with invalid annotations are skipped and reported as warnings
+func AnnotatedServers(resources *K8sResources) ([]*server.Server, []string) {
+	var servers []*server.Server
+	var warnings []string
+
+	add := func(workload metav1.Object, kind string, selector *metav1.LabelSelector) {
+		value, ok := workload.GetAnnotations()[ServersAnnotation]
+		if !ok {
+			return
+		}
+
+		ports, err := ParseServersAnnotation(value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s/%s/%s: %s", workload.GetNamespace(), kind, workload.GetName(), err))
+			return
+		}
+
+		for _, port := range ports {
+			srv := newServer(workload.GetNamespace(), workload.GetName(), selector.DeepCopy(), port.Port)
+			srv.Spec.ProxyProtocol = port.ProxyProtocol
+			srv.Labels[ManagedByLabel] = ManagedByServersController
+			srv.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(workload, appsv1.SchemeGroupVersion.WithKind(kind)),
+			}
+			servers = append(servers, srv)
+		}
+	}
+
+	if resources.Deployments != nil {
+		for i := range resources.Deployments.Items {
+			obj := &resources.Deployments.Items[i]
+			add(obj, DeploymentKind, obj.Spec.Selector)
+		}
+	}
+
+	if resources.StatefulSets != nil {
+		for i := range resources.StatefulSets.Items {
+			obj := &resources.StatefulSets.Items[i]
+			add(obj, StatefulSetKind, obj.Spec.Selector)
+		}
+	}
+
+	if resources.DaemonSets != nil {
+		for i := range resources.DaemonSets.Items {
+			obj := &resources.DaemonSets.Items[i]
+			add(obj, DaemonSetKind, obj.Spec.Selector)
+		}
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].Namespace != servers[j].Namespace {
+			return servers[i].Namespace < servers[j].Namespace
+		}
+		return servers[i].Name < servers[j].Name
+	})
+
+	return servers, warnings
+}
@@ -0,0 +1,379 @@
+package common
+
+import (
+	"context"
+	"errors"
+	l5dcrdinformer "github.com/linkerd/linkerd2/controller/gen/client/informers/externalversions"
+	pkgK8s "github.com/linkerd/linkerd2/controller/k8s"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"time"
+)
+
+// ResourceSource provides a snapshot of resources for a namespace (or all namespaces if namespace is empty)
+type ResourceSource interface {
+	Fetch(ctx context.Context, namespace string) (*K8sResources, error)
+}
+
+// ClusterSource reads resources from a live cluster
+type ClusterSource struct {
+	KubeconfigPath   string
+	KubeContext      string
+	Impersonate      string
+	ImpersonateGroup []string
+}
+
+// ManifestSource reads resources from manifest files, directories or stdin
+type ManifestSource struct {
+	Paths                 []string
+	ControlPlaneNamespace string
+	// DefaultNamespace is used for objects without namespace when all namespaces are fetched
+	DefaultNamespace string
+}
+
+// StaticSource serves resources from memory, it is useful for tests and fixtures
+type StaticSource struct {
+	Resources *K8sResources
+}
+
+func (s *ClusterSource) Fetch(ctx context.Context, namespace string) (*K8sResources, error) {
+	k8sAPI, err := k8s.NewAPI(s.KubeconfigPath, s.KubeContext, s.Impersonate, s.ImpersonateGroup, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// informers are only needed for this snapshot
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lr5dAPI, err := initServerAPI(ctx, k8sAPI.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := fetchNamespaces(ctx, k8sAPI, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := k8sAPI.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := k8sAPI.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := k8sAPI.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	statefulSets, err := k8sAPI.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	daemonSets, err := k8sAPI.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := lr5dAPI.Server().V1beta1().Servers().Lister().Servers(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	serverAuthorizations, err := lr5dAPI.Serverauthorization().V1beta1().ServerAuthorizations().Lister().ServerAuthorizations(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationPolicies, err := lr5dAPI.Policy().V1alpha1().AuthorizationPolicies().Lister().AuthorizationPolicies(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	httpRoutes, err := lr5dAPI.Policy().V1alpha1().HTTPRoutes().Lister().HTTPRoutes(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	meshTLSAuthentications, err := lr5dAPI.Policy().V1alpha1().MeshTLSAuthentications().Lister().MeshTLSAuthentications(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	newtworkAuthentications, err := lr5dAPI.Policy().V1alpha1().NetworkAuthentications().Lister().NetworkAuthentications(namespace).List(labels.NewSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	return &K8sResources{
+		Namespaces:             namespaces,
+		Pods:                   pods,
+		Services:               services,
+		Deployments:            deployments,
+		StatefulSets:           statefulSets,
+		DaemonSets:             daemonSets,
+		Servers:                servers,
+		ServerAuthorizations:   serverAuthorizations,
+		AuthorizationPolicies:  authorizationPolicies,
+		HTTPRoutes:             httpRoutes,
+		MeshTLSAuthentications: meshTLSAuthentications,
+		NetworkAuthentications: newtworkAuthentications,
+	}, nil
+}
+
+func (s *ManifestSource) Fetch(ctx context.Context, namespace string) (*K8sResources, error) {
+	defaultNamespace := namespace
+	if defaultNamespace == "" {
+		defaultNamespace = s.DefaultNamespace
+	}
+
+	resources, err := LoadManifestFiles(s.Paths, defaultNamespace, s.ControlPlaneNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return resources.InNamespace(namespace), nil
+}
+
+func (s *StaticSource) Fetch(ctx context.Context, namespace string) (*K8sResources, error) {
+	return s.Resources.InNamespace(namespace), nil
+}
+
+// InNamespace returns resources from the given namespace only, or all resources if namespace is empty
+func (r *K8sResources) InNamespace(namespace string) *K8sResources {
+	if namespace == "" {
+		return r
+	}
+
+	filtered := &K8sResources{
+		Namespaces:   &v1.NamespaceList{},
+		Pods:         &v1.PodList{},
+		Services:     &v1.ServiceList{},
+		Deployments:  &appsv1.DeploymentList{},
+		StatefulSets: &appsv1.StatefulSetList{},
+		DaemonSets:   &appsv1.DaemonSetList{},
+	}
+
+	if ns := r.Namespace(namespace); ns != nil {
+		filtered.Namespaces.Items = append(filtered.Namespaces.Items, *ns)
+	}
+
+	if r.Pods != nil {
+		for _, obj := range r.Pods.Items {
+			if obj.Namespace == namespace {
+				filtered.Pods.Items = append(filtered.Pods.Items, obj)
+			}
+		}
+	}
+
+	if r.Services != nil {
+		for _, obj := range r.Services.Items {
+			if obj.Namespace == namespace {
+				filtered.Services.Items = append(filtered.Services.Items, obj)
+			}
+		}
+	}
+
+	if r.Deployments != nil {
+		for _, obj := range r.Deployments.Items {
+			if obj.Namespace == namespace {
+				filtered.Deployments.Items = append(filtered.Deployments.Items, obj)
+			}
+		}
+	}
+
+	if r.StatefulSets != nil {
+		for _, obj := range r.StatefulSets.Items {
+			if obj.Namespace == namespace {
+				filtered.StatefulSets.Items = append(filtered.StatefulSets.Items, obj)
+			}
+		}
+	}
+
+	if r.DaemonSets != nil {
+		for _, obj := range r.DaemonSets.Items {
+			if obj.Namespace == namespace {
+				filtered.DaemonSets.Items = append(filtered.DaemonSets.Items, obj)
+			}
+		}
+	}
+
+	for _, obj := range r.Servers {
+		if obj.Namespace == namespace {
+			filtered.Servers = append(filtered.Servers, obj)
+		}
+	}
+
+	for _, obj := range r.ServerAuthorizations {
+		if obj.Namespace == namespace {
+			filtered.ServerAuthorizations = append(filtered.ServerAuthorizations, obj)
+		}
+	}
+
+	for _, obj := range r.AuthorizationPolicies {
+		if obj.Namespace == namespace {
+			filtered.AuthorizationPolicies = append(filtered.AuthorizationPolicies, obj)
+		}
+	}
+
+	for _, obj := range r.HTTPRoutes {
+		if obj.Namespace == namespace {
+			filtered.HTTPRoutes = append(filtered.HTTPRoutes, obj)
+		}
+	}
+
+	for _, obj := range r.MeshTLSAuthentications {
+		if obj.Namespace == namespace {
+			filtered.MeshTLSAuthentications = append(filtered.MeshTLSAuthentications, obj)
+		}
+	}
+
+	for _, obj := range r.NetworkAuthentications {
+		if obj.Namespace == namespace {
+			filtered.NetworkAuthentications = append(filtered.NetworkAuthentications, obj)
+		}
+	}
+
+	return filtered
+}
+
+// ObjectNamespaces returns sorted namespaces of all namespaced objects, objects without namespace are skipped
+func (r *K8sResources) ObjectNamespaces() []string {
+	seen := map[string]bool{}
+	add := func(namespace string) {
+		if namespace != "" {
+			seen[namespace] = true
+		}
+	}
+
+	if r.Pods != nil {
+		for _, obj := range r.Pods.Items {
+			add(obj.Namespace)
+		}
+	}
+
+	if r.Services != nil {
+		for _, obj := range r.Services.Items {
+			add(obj.Namespace)
+		}
+	}
+
+	if r.Deployments != nil {
+		for _, obj := range r.Deployments.Items {
+			add(obj.Namespace)
+		}
+	}
+
+	if r.StatefulSets != nil {
+		for _, obj := range r.StatefulSets.Items {
+			add(obj.Namespace)
+		}
+	}
+
+	if r.DaemonSets != nil {
+		for _, obj := range r.DaemonSets.Items {
+			add(obj.Namespace)
+		}
+	}
+
+	for _, obj := range r.Servers {
+		add(obj.Namespace)
+	}
+
+	for _, obj := range r.ServerAuthorizations {
+		add(obj.Namespace)
+	}
+
+	for _, obj := range r.AuthorizationPolicies {
+		add(obj.Namespace)
+	}
+
+	for _, obj := range r.HTTPRoutes {
+		add(obj.Namespace)
+	}
+
+	for _, obj := range r.MeshTLSAuthentications {
+		add(obj.Namespace)
+	}
+
+	for _, obj := range r.NetworkAuthentications {
+		add(obj.Namespace)
+	}
+
+	namespaces := make([]string, 0, len(seen))
+	for namespace := range seen {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// fetchNamespaces returns the namespace or all namespaces, namespaces are optional for checks
+// so missing permissions for cluster scoped resources are not an error
+func fetchNamespaces(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace string) (*v1.NamespaceList, error) {
+	if namespace == "" {
+		namespaces, err := k8sAPI.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if kerrors.IsForbidden(err) {
+			return &v1.NamespaceList{}, nil
+		}
+		return namespaces, err
+	}
+
+	ns, err := k8sAPI.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if kerrors.IsForbidden(err) || kerrors.IsNotFound(err) {
+		return &v1.NamespaceList{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.NamespaceList{Items: []v1.Namespace{*ns}}, nil
+}
+
+// initServerAPI starts informers of Linkerd policy resources that run until ctx is done and waits for all of their caches
+func initServerAPI(ctx context.Context, config *rest.Config) (l5dcrdinformer.SharedInformerFactory, error) {
+	lr5dClient, err := pkgK8s.NewL5DCRDClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	lr5dAPI := l5dcrdinformer.NewSharedInformerFactory(lr5dClient, 10*time.Minute)
+
+	informers := []cache.SharedIndexInformer{
+		lr5dAPI.Server().V1beta1().Servers().Informer(),
+		lr5dAPI.Serverauthorization().V1beta1().ServerAuthorizations().Informer(),
+		lr5dAPI.Policy().V1alpha1().AuthorizationPolicies().Informer(),
+		lr5dAPI.Policy().V1alpha1().MeshTLSAuthentications().Informer(),
+		lr5dAPI.Policy().V1alpha1().NetworkAuthentications().Informer(),
+		lr5dAPI.Policy().V1alpha1().HTTPRoutes().Informer(),
+	}
+
+	synced := []cache.InformerSynced{}
+	for _, informer := range informers {
+		go informer.Run(ctx.Done())
+		synced = append(synced, informer.HasSynced)
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		return nil, errors.New("failed to initialized client")
+	}
+
+	return lr5dAPI, nil
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"io"
+	"sort"
+)
+
+// Labels of Linkerd proxy metrics that are used to find out who talks to a Server
+const (
+	namespaceMetricLabel = "namespace"
+	directionMetricLabel = "direction"
+	srvKindMetricLabel   = "srv_kind"
+	srvNameMetricLabel   = "srv_name"
+	clientIDMetricLabel  = "client_id"
+
+	inboundDirection = "inbound"
+	serverSrvKind    = "server"
+
+	requestTotalMetric = "request_total"
+	tcpOpenTotalMetric = "tcp_open_total"
+)
+
+// inboundTrafficMetrics are metrics of allowed traffic only, deny and terminate series of the same
+// authorization metrics count rejected clients that must not get access from generated policies
+var inboundTrafficMetrics = map[string]bool{
+	"inbound_http_authz_allow_total": true,
+	"inbound_tcp_authz_allow_total":  true,
+	requestTotalMetric:               true,
+	tcpOpenTotalMetric:               true,
+}
+
+// ObservedTraffic is the traffic to a single Server seen in proxy metrics
+type ObservedTraffic struct {
+	Server                  ObjectReference
+	Identities              map[string]float64
+	UnauthenticatedRequests float64
+}
+
+// ObservedTrafficFromMetrics reads metrics in Prometheus text exposition format and returns traffic by Server,
+// only samples of Servers (not default policies) with the namespace label are taken into account
+func ObservedTrafficFromMetrics(r io.Reader) ([]ObservedTraffic, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+
+	traffic := map[string]*ObservedTraffic{}
+
+	for name, family := range families {
+		if !inboundTrafficMetrics[name] {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if (name == requestTotalMetric || name == tcpOpenTotalMetric) && labels[directionMetricLabel] != inboundDirection {
+				continue
+			}
+
+			if labels[srvKindMetricLabel] != serverSrvKind || labels[srvNameMetricLabel] == "" || labels[namespaceMetricLabel] == "" {
+				continue
+			}
+
+			value := sampleValue(metric)
+			if value <= 0 {
+				continue
+			}
+
+			srv := ObjectReference{Kind: ServerKind, Namespace: labels[namespaceMetricLabel], Name: labels[srvNameMetricLabel]}
+
+			observed, ok := traffic[srv.String()]
+			if !ok {
+				observed = &ObservedTraffic{Server: srv, Identities: map[string]float64{}}
+				traffic[srv.String()] = observed
+			}
+
+			if identity := labels[clientIDMetricLabel]; identity != "" {
+				observed.Identities[identity] += value
+			} else {
+				observed.UnauthenticatedRequests += value
+			}
+		}
+	}
+
+	result := make([]ObservedTraffic, 0, len(traffic))
+	for _, observed := range traffic {
+		result = append(result, *observed)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Server.String() < result[j].Server.String()
+	})
+
+	return result, nil
+}
+
+// SortedIdentities returns observed client identities in a stable order
+func (t ObservedTraffic) SortedIdentities() []string {
+	identities := make([]string, 0, len(t.Identities))
+	for identity := range t.Identities {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	return identities
+}
+
+func sampleValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.GetCounter() != nil:
+		return metric.GetCounter().GetValue()
+	case metric.GetGauge() != nil:
+		return metric.GetGauge().GetValue()
+	default:
+		return metric.GetUntyped().GetValue()
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+const trafficMetrics = `
+# TYPE inbound_http_authz_allow_total counter
+inbound_http_authz_allow_total{namespace="app",srv_kind="server",srv_name="web",client_id="client.app.serviceaccount.identity.linkerd.cluster.local"} 10
+# TYPE inbound_http_authz_deny_total counter
+inbound_http_authz_deny_total{namespace="app",srv_kind="server",srv_name="web",client_id="intruder.app.serviceaccount.identity.linkerd.cluster.local"} 3
+# TYPE inbound_tcp_authz_deny_total counter
+inbound_tcp_authz_deny_total{namespace="app",srv_kind="server",srv_name="db",client_id="intruder.app.serviceaccount.identity.linkerd.cluster.local"} 1
+# TYPE inbound_tcp_authz_terminate_total counter
+inbound_tcp_authz_terminate_total{namespace="app",srv_kind="server",srv_name="db",client_id="revoked.app.serviceaccount.identity.linkerd.cluster.local"} 2
+# TYPE inbound_tcp_authz_allow_total counter
+inbound_tcp_authz_allow_total{namespace="app",srv_kind="server",srv_name="db",client_id="web.app.serviceaccount.identity.linkerd.cluster.local"} 5
+# TYPE request_total counter
+request_total{direction="inbound",namespace="app",srv_kind="server",srv_name="web"} 4
+request_total{direction="outbound",namespace="app",srv_kind="server",srv_name="api",client_id="web.app.serviceaccount.identity.linkerd.cluster.local"} 7
+request_total{direction="inbound",namespace="app",srv_kind="default",srv_name="all-unauthenticated"} 7
+# TYPE tcp_open_total counter
+tcp_open_total{direction="inbound",namespace="app",srv_kind="server",srv_name="cache",client_id="web.app.serviceaccount.identity.linkerd.cluster.local"} 1
+`
+
+func TestObservedTrafficFromMetrics(t *testing.T) {
+	traffic, err := ObservedTrafficFromMetrics(strings.NewReader(trafficMetrics))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []struct {
+		server          string
+		identities      []string
+		unauthenticated float64
+	}{
+		{server: "app/Server/cache", identities: []string{"web.app.serviceaccount.identity.linkerd.cluster.local"}},
+		{server: "app/Server/db", identities: []string{"web.app.serviceaccount.identity.linkerd.cluster.local"}},
+		{server: "app/Server/web", identities: []string{"client.app.serviceaccount.identity.linkerd.cluster.local"}, unauthenticated: 4},
+	}
+
+	if len(traffic) != len(expected) {
+		t.Fatalf("expected %d servers, got %v", len(expected), traffic)
+	}
+
+	for i, exp := range expected {
+		observed := traffic[i]
+		if observed.Server.String() != exp.server {
+			t.Errorf("expected server %s, got %s", exp.server, observed.Server)
+		}
+
+		identities := observed.SortedIdentities()
+		if strings.Join(identities, ",") != strings.Join(exp.identities, ",") {
+			t.Errorf("%s: expected identities %v, got %v", exp.server, exp.identities, identities)
+		}
+
+		if observed.UnauthenticatedRequests != exp.unauthenticated {
+			t.Errorf("%s: expected %v unauthenticated requests, got %v", exp.server, exp.unauthenticated, observed.UnauthenticatedRequests)
+		}
+	}
+}
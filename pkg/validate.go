@@ -0,0 +1,139 @@
+package common
+
+import (
+	"fmt"
+	policy "github.com/linkerd/linkerd2/controller/gen/apis/policy/v1alpha1"
+	server "github.com/linkerd/linkerd2/controller/gen/apis/server/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	MissingAuthenticationRule = "missing-authentication"
+	ServerPortNotExposedRule  = "server-port-not-exposed"
+)
+
+// ValidatePolicyObject returns findings for a Server, HTTPRoute or AuthorizationPolicy that is about to be created,
+// the object is added to fetched resources and evaluated by the same rules that authcheck uses
+func ValidatePolicyObject(resources *K8sResources, obj runtime.Object) ([]Finding, error) {
+	var ref ObjectReference
+	var checks []Check
+
+	switch o := obj.(type) {
+	case *policy.AuthorizationPolicy:
+		ref = ObjectReference{Kind: AuthorizationPolicyKind, Namespace: o.Namespace, Name: o.Name}
+		resources.AuthorizationPolicies = append(resources.AuthorizationPolicies, o)
+		checks = []Check{policyWithoutServerCheck(), missingAuthenticationCheck()}
+	case *policy.HTTPRoute:
+		ref = ObjectReference{Kind: HTTPRouteKind, Namespace: o.Namespace, Name: o.Name}
+		resources.HTTPRoutes = append(resources.HTTPRoutes, o)
+		checks = []Check{obsoleteHTTPRouteCheck()}
+	case *server.Server:
+		ref = ObjectReference{Kind: ServerKind, Namespace: o.Namespace, Name: o.Name}
+		resources.Servers = append(resources.Servers, o)
+		checks = []Check{serverPortNotExposedCheck()}
+	default:
+		return nil, fmt.Errorf("unsupported object %T", obj)
+	}
+
+	findings := []Finding{}
+	for _, check := range checks {
+		checkFindings, err := check.Run(resources)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, finding := range checkFindings {
+			if finding.Object == ref {
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+func missingAuthenticationCheck() Check {
+	check := Check{
+		ID:          MissingAuthenticationRule,
+		Description: "linkerd-easyauth no authorization policies with missing authentications",
+		Summary:     "Some authorization policies require authentications that don't exist",
+		Severity:    SeverityWarning,
+		Remediation: "create the authentication or fix requiredAuthenticationRefs of the AuthorizationPolicy",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, authzPolicy := range resources.AuthorizationPolicies {
+			for _, authnRef := range authzPolicy.Spec.RequiredAuthenticationRefs {
+				// ServiceAccounts are not fetched, only authentications are checked
+				if authnRef.Kind != MeshTLSAuthenticationKind && authnRef.Kind != NetworkAuthenticationKind {
+					continue
+				}
+
+				// resources are fetched per namespace, authentications from other namespaces are not checked
+				if authnRef.Namespace != nil && *authnRef.Namespace != "" && string(*authnRef.Namespace) != authzPolicy.GetNamespace() {
+					continue
+				}
+
+				ref := ObjectReference{Kind: string(authnRef.Kind), Namespace: authzPolicy.GetNamespace(), Name: string(authnRef.Name)}
+				if resources.Object(ref) == nil {
+					findings = append(findings, check.finding(
+						ObjectReference{Kind: AuthorizationPolicyKind, Namespace: authzPolicy.GetNamespace(), Name: authzPolicy.GetName()},
+						"Authorization Policy %s requires %s %s that does not exist", authzPolicy.GetName(), authnRef.Kind, authnRef.Name,
+					))
+				}
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}
+
+func serverPortNotExposedCheck() Check {
+	check := Check{
+		ID:          ServerPortNotExposedRule,
+		Description: "linkerd-easyauth no Servers with ports that are not exposed by pods",
+		Summary:     "Some servers have ports that are not exposed by any selected pod",
+		Severity:    SeverityWarning,
+		Remediation: "fix the port or podSelector of the Server so it matches a container port",
+	}
+
+	check.Run = func(resources *K8sResources) ([]Finding, error) {
+		findings := []Finding{}
+
+		for _, srv := range resources.Servers {
+			selector, err := metav1.LabelSelectorAsSelector(srv.Spec.PodSelector)
+			if err != nil {
+				return nil, err
+			}
+
+			exposed := false
+			for _, pod := range resources.Pods.Items {
+				if pod.Namespace != srv.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+
+				if _, ok := ServerContainerPort(srv, pod); ok {
+					exposed = true
+					break
+				}
+			}
+
+			if !exposed {
+				findings = append(findings, check.finding(
+					ObjectReference{Kind: ServerKind, Namespace: srv.GetNamespace(), Name: srv.GetName()},
+					"Server %s port %s is not exposed by any selected pod", srv.GetName(), srv.Spec.Port.String(),
+				))
+			}
+		}
+
+		return findings, nil
+	}
+
+	return check
+}